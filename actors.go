@@ -0,0 +1,57 @@
+package main
+
+import "sort"
+
+const (
+	defaultDeploymentActorLimit = 20
+	otherActor                  = "other"
+	unknownActor                = "unknown"
+)
+
+// deploymentActor returns the label a deployment triggered by login is
+// counted under: the login's team from DEPLOYMENT_ACTOR_TEAMS if it has one,
+// the login itself otherwise.
+func deploymentActor(login string) string {
+	if login == "" {
+		return unknownActor
+	}
+	if team, ok := cfg.DeploymentActorTeams[login]; ok {
+		return team
+	}
+	return login
+}
+
+// deploymentsByActor counts attempts per deploymentActor. To bound the number
+// of series, actors outside DEPLOYMENT_ACTOR_ALLOWLIST, and those beyond the
+// DeploymentActorLimit busiest, are counted as "other".
+func deploymentsByActor(attempts []deploymentAttempt) map[string]int {
+	counts := make(map[string]int)
+	for _, attempt := range attempts {
+		actor := deploymentActor(attempt.Actor)
+		if cfg.DeploymentActorAllowlist != nil && !cfg.DeploymentActorAllowlist[actor] {
+			actor = otherActor
+		}
+		counts[actor]++
+	}
+
+	actors := make([]string, 0, len(counts))
+	for actor := range counts {
+		if actor != otherActor {
+			actors = append(actors, actor)
+		}
+	}
+	if len(actors) <= cfg.DeploymentActorLimit {
+		return counts
+	}
+	sort.Slice(actors, func(i, j int) bool {
+		if counts[actors[i]] != counts[actors[j]] {
+			return counts[actors[i]] > counts[actors[j]]
+		}
+		return actors[i] < actors[j]
+	})
+	for _, actor := range actors[cfg.DeploymentActorLimit:] {
+		counts[otherActor] += counts[actor]
+		delete(counts, actor)
+	}
+	return counts
+}
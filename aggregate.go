@@ -0,0 +1,7 @@
+package main
+
+// allBranches is the branch label of the series aggregated across every
+// branch of a repository. Its metrics are computed from the deployments and
+// runs of all branches at once, so re-runs are counted once rather than once
+// per branch as summing the per-branch series would.
+const allBranches = "__all__"
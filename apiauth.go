@@ -0,0 +1,55 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+)
+
+// apiCredentials are the credentials the query endpoints accept, from
+// API_AUTH_TOKEN or API_AUTH_USERNAME and API_AUTH_PASSWORD. Either may be
+// empty.
+type apiCredentials struct {
+	token    string
+	username string
+	password string
+}
+
+func (c apiCredentials) enabled() bool {
+	return c.token != "" || c.username != ""
+}
+
+// allows reports whether r carries the bearer token or the basic auth
+// credentials.
+func (c apiCredentials) allows(r *http.Request) bool {
+	if c.token != "" && hasAdminToken(r, c.token) {
+		return true
+	}
+	if c.username == "" {
+		return false
+	}
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return false
+	}
+	usernameMatches := subtle.ConstantTimeCompare([]byte(username), []byte(c.username)) == 1
+	passwordMatches := subtle.ConstantTimeCompare([]byte(password), []byte(c.password)) == 1
+	return usernameMatches && passwordMatches
+}
+
+// withAPIAuth rejects requests to next that do not carry credentials. It
+// returns next unchanged when no credentials are configured.
+func withAPIAuth(next http.Handler, credentials apiCredentials) http.Handler {
+	if !credentials.enabled() {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !credentials.allows(r) {
+			if credentials.username != "" {
+				w.Header().Set("WWW-Authenticate", `Basic realm="dora"`)
+			}
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxAuditResultBytes bounds how much of the response is kept as the result.
+const maxAuditResultBytes = 200
+
+// auditRecord is one line of the webhook audit log.
+type auditRecord struct {
+	Time       time.Time `json:"time"`
+	DeliveryID string    `json:"delivery_id,omitempty"`
+	Event      string    `json:"event,omitempty"`
+	Repo       string    `json:"repo,omitempty"`
+	Branch     string    `json:"branch,omitempty"`
+	// Signature is "valid", "missing" or "mismatch", or empty if the
+	// delivery was rejected before its signature was checked.
+	Signature string `json:"signature,omitempty"`
+	Status    int    `json:"status"`
+	Result    string `json:"result"`
+}
+
+// auditLog appends auditRecords as JSON lines to a file. Each record is synced
+// to disk before the delivery is answered.
+type auditLog struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// openAuditLog opens the audit log at path for appending, creating it if
+// needed. An empty path returns nil, which audits nothing.
+func openAuditLog(path string) (*auditLog, error) {
+	if path == "" {
+		return nil, nil
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("opening WEBHOOK_AUDIT_LOG: %w", err)
+	}
+	return &auditLog{file: file}, nil
+}
+
+func (l *auditLog) write(record *auditRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.file.Write(line); err != nil {
+		return err
+	}
+	return l.file.Sync()
+}
+
+type auditRecordKey struct{}
+
+// auditFromContext returns the record of the delivery being handled, for the
+// webhook handlers to fill in. Without an audit log the record is discarded.
+func auditFromContext(ctx context.Context) *auditRecord {
+	if record, ok := ctx.Value(auditRecordKey{}).(*auditRecord); ok {
+		return record
+	}
+	return &auditRecord{}
+}
+
+// auditResponseWriter captures the status and the start of the body of the
+// response to a delivery.
+type auditResponseWriter struct {
+	http.ResponseWriter
+	status int
+	body   strings.Builder
+}
+
+func (w *auditResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *auditResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if remaining := maxAuditResultBytes - w.body.Len(); remaining > 0 {
+		w.body.Write(b[:min(len(b), remaining)])
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// withAuditLog records every delivery passed to next in auditLog. The webhook
+// handlers add the repo, branch and signature outcome via auditFromContext.
+func withAuditLog(next http.HandlerFunc, audit *auditLog) http.HandlerFunc {
+	if audit == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		record := &auditRecord{
+			Time:       time.Now().UTC(),
+			DeliveryID: firstHeader(r, "X-GitHub-Delivery", "X-Gitlab-Event-UUID"),
+			Event:      firstHeader(r, "X-GitHub-Event", "X-Gitlab-Event"),
+		}
+		recorder := &auditResponseWriter{ResponseWriter: w}
+		next(recorder, r.WithContext(context.WithValue(r.Context(), auditRecordKey{}, record)))
+
+		record.Status = recorder.status
+		if record.Status == 0 {
+			record.Status = http.StatusOK
+		}
+		record.Result = strings.TrimSpace(recorder.body.String())
+		// Successful recalculations answer with the metrics themselves,
+		// which are not worth keeping in the log.
+		if strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+			record.Result = "Recomputed metrics"
+		}
+		if err := audit.write(record); err != nil {
+			log.Printf("Error writing audit log: %v", err)
+		}
+	}
+}
+
+// firstHeader returns the first of the named request headers that is set.
+func firstHeader(r *http.Request, names ...string) string {
+	for _, name := range names {
+		if v := r.Header.Get(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/google/go-github/v45/github"
+)
+
+const (
+	// backfillRetries is how many times a rate limited request is retried
+	// during a backfill before the window is given up.
+	backfillRetries = 5
+	// defaultBackfillBackoff is how long to wait after a rate limit error
+	// that does not say when to retry.
+	defaultBackfillBackoff = time.Minute
+	backfillDateLayout     = "2006-01-02"
+)
+
+// parseBackfillRange parses the -from and -to dates of -backfill, both
+// inclusive, as UTC days.
+func parseBackfillRange(from string, to string) (time.Time, time.Time, error) {
+	start, err := time.Parse(backfillDateLayout, from)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid -from %q: expected YYYY-MM-DD", from)
+	}
+	end, err := time.Parse(backfillDateLayout, to)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid -to %q: expected YYYY-MM-DD", to)
+	}
+	if end.Before(start) {
+		return time.Time{}, time.Time{}, fmt.Errorf("-to %s is before -from %s", to, from)
+	}
+	return start, end, nil
+}
+
+// runBackfill computes the metrics of repo/branch as they stood at the end of
+// every UTC day from from to to, writes each snapshot to out as a line of
+// JSON and returns the process exit code: 0 on success, 1 if a snapshot
+// could not be computed or any of its metrics failed.
+func runBackfill(calculator *Calculator, repoFullName string, branch string, from time.Time, to time.Time, out io.Writer) int {
+	encoder := json.NewEncoder(out)
+	exitCode := 0
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		asOf := day.AddDate(0, 0, 1)
+		dayCalculator := calculator.withProvider(&asOfProvider{Provider: calculator.provider, until: asOf, sleep: time.Sleep})
+		dayCalculator.now = func() time.Time { return asOf }
+
+		metrics, err := dayCalculator.Compute(context.Background(), repoFullName, branch)
+		if err != nil {
+			log.Printf("Error calculating DORA metrics as of %s: %v", asOf.Format(time.RFC3339), err)
+			return 1
+		}
+		if err := encoder.Encode(metrics); err != nil {
+			log.Printf("Error encoding metrics to JSON: %v", err)
+			return 1
+		}
+		if len(metrics.Errors) > 0 {
+			log.Printf("%d of the DORA metrics as of %s could not be calculated", len(metrics.Errors), asOf.Format(time.RFC3339))
+			exitCode = 1
+		}
+	}
+	return exitCode
+}
+
+// asOfProvider makes a Provider return what it would have at until: what
+// was created, finished, merged or closed later is left out. Rate limited
+// requests are retried once the limit resets, so that a long backfill
+// waits out the limit rather than fail.
+type asOfProvider struct {
+	Provider
+	until time.Time
+	sleep func(time.Duration)
+}
+
+// retry calls fn until it succeeds, returns an error other than a rate
+// limit error, or has been rate limited backfillRetries times.
+func (p *asOfProvider) retry(fn func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		wait, limited := rateLimitWait(err)
+		if !limited || attempt == backfillRetries {
+			return err
+		}
+		log.Printf("Rate limited, retrying in %s: %v", wait, err)
+		p.sleep(wait)
+	}
+}
+
+// rateLimitWait reports whether err is a rate limit error, including one
+// that left a listing incomplete, and how long to wait before retrying.
+func rateLimitWait(err error) (time.Duration, bool) {
+	var rateLimitErr *github.RateLimitError
+	var abuseErr *github.AbuseRateLimitError
+	var gitlabErr *gitlabRateLimitError
+	var wait time.Duration
+	switch {
+	case errors.As(err, &rateLimitErr):
+		wait = time.Until(rateLimitErr.Rate.Reset.Time)
+	case errors.As(err, &abuseErr):
+		wait = abuseErr.GetRetryAfter()
+	case errors.As(err, &gitlabErr):
+		wait = gitlabErr.retryAfter
+	default:
+		return 0, false
+	}
+	if wait <= 0 {
+		wait = defaultBackfillBackoff
+	}
+	return wait, true
+}
+
+func (p *asOfProvider) done(t time.Time) bool {
+	return !t.After(p.until)
+}
+
+func (p *asOfProvider) ListDeploymentAttempts(repoFullName string, branch string, since time.Time) ([]deploymentAttempt, error) {
+	var attempts []deploymentAttempt
+	listErr := p.retry(func() (err error) {
+		attempts, err = p.Provider.ListDeploymentAttempts(repoFullName, branch, since)
+		return err
+	})
+	if listErr != nil && !isPartialList(listErr) {
+		return nil, listErr
+	}
+	var filtered []deploymentAttempt
+	for _, attempt := range attempts {
+		if p.done(attempt.CompletedAt) {
+			filtered = append(filtered, attempt)
+		}
+	}
+	return filtered, listErr
+}
+
+func (p *asOfProvider) ListPipelineRuns(repoFullName string, branch string, since time.Time) ([]pipelineRun, error) {
+	var runs []pipelineRun
+	listErr := p.retry(func() (err error) {
+		runs, err = p.Provider.ListPipelineRuns(repoFullName, branch, since)
+		return err
+	})
+	if listErr != nil && !isPartialList(listErr) {
+		return nil, listErr
+	}
+	var filtered []pipelineRun
+	for _, run := range runs {
+		if p.done(run.CompletedAt) {
+			filtered = append(filtered, run)
+		}
+	}
+	return filtered, listErr
+}
+
+// ListEnvironmentDeployments leaves out the deployments created after until.
+// One that finished later is reported as still in progress.
+func (p *asOfProvider) ListEnvironmentDeployments(repoFullName string, branch string, environment string, since time.Time) ([]deploymentResult, error) {
+	var deployments []deploymentResult
+	listErr := p.retry(func() (err error) {
+		deployments, err = p.Provider.ListEnvironmentDeployments(repoFullName, branch, environment, since)
+		return err
+	})
+	if listErr != nil && !isPartialList(listErr) {
+		return nil, listErr
+	}
+	var filtered []deploymentResult
+	for _, deployment := range deployments {
+		if !p.done(deployment.CreatedAt) {
+			continue
+		}
+		if !p.done(deployment.FinishedAt) {
+			deployment.State = "in_progress"
+			deployment.FinishedAt = time.Time{}
+		}
+		filtered = append(filtered, deployment)
+	}
+	return filtered, listErr
+}
+
+func (p *asOfProvider) ListIncidents(repoFullName string, since time.Time) ([]incident, error) {
+	var incidents []incident
+	listErr := p.retry(func() (err error) {
+		incidents, err = p.Provider.ListIncidents(repoFullName, since)
+		return err
+	})
+	if listErr != nil && !isPartialList(listErr) {
+		return nil, listErr
+	}
+	var filtered []incident
+	for _, incident := range incidents {
+		if p.done(incident.ClosedAt) {
+			filtered = append(filtered, incident)
+		}
+	}
+	return filtered, listErr
+}
+
+// ListOpenIncidents leaves out the incidents opened after until. Incidents
+// that were open at until but have been closed since are not included.
+func (p *asOfProvider) ListOpenIncidents(repoFullName string) ([]incident, error) {
+	var incidents []incident
+	listErr := p.retry(func() (err error) {
+		incidents, err = p.Provider.ListOpenIncidents(repoFullName)
+		return err
+	})
+	if listErr != nil && !isPartialList(listErr) {
+		return nil, listErr
+	}
+	var filtered []incident
+	for _, incident := range incidents {
+		if p.done(incident.CreatedAt) {
+			filtered = append(filtered, incident)
+		}
+	}
+	return filtered, listErr
+}
+
+func (p *asOfProvider) ListMergedPullRequests(repoFullName string, branch string, since time.Time) ([]mergedPullRequest, error) {
+	var pulls []mergedPullRequest
+	listErr := p.retry(func() (err error) {
+		pulls, err = p.Provider.ListMergedPullRequests(repoFullName, branch, since)
+		return err
+	})
+	if listErr != nil && !isPartialList(listErr) {
+		return nil, listErr
+	}
+	var filtered []mergedPullRequest
+	for _, pull := range pulls {
+		if p.done(pull.MergedAt) {
+			filtered = append(filtered, pull)
+		}
+	}
+	return filtered, listErr
+}
+
+func (p *asOfProvider) GetLabeledAt(repoFullName string, number int, label string) (time.Time, error) {
+	var labeledAt time.Time
+	err := p.retry(func() (err error) {
+		labeledAt, err = p.Provider.GetLabeledAt(repoFullName, number, label)
+		return err
+	})
+	return labeledAt, err
+}
+
+func (p *asOfProvider) ListCommitTimes(repoFullName string, base string, head string) ([]time.Time, error) {
+	var times []time.Time
+	err := p.retry(func() (err error) {
+		times, err = p.Provider.ListCommitTimes(repoFullName, base, head)
+		return err
+	})
+	return times, err
+}
+
+func (p *asOfProvider) ListChangeLabels(repoFullName string, sha string) ([]string, error) {
+	var labels []string
+	err := p.retry(func() (err error) {
+		labels, err = p.Provider.ListChangeLabels(repoFullName, sha)
+		return err
+	})
+	return labels, err
+}
+
+func (p *asOfProvider) ListChangedFiles(repoFullName string, sha string) ([]string, error) {
+	var files []string
+	err := p.retry(func() (err error) {
+		files, err = p.Provider.ListChangedFiles(repoFullName, sha)
+		return err
+	})
+	return files, err
+}
+
+func (p *asOfProvider) GetRepository(repoFullName string) (*repositoryInfo, error) {
+	var repository *repositoryInfo
+	err := p.retry(func() (err error) {
+		repository, err = p.Provider.GetRepository(repoFullName)
+		return err
+	})
+	return repository, err
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v45/github"
+)
+
+func TestRunBackfillComputesEachDayAsOfItsEnd(t *testing.T) {
+	setupWebhookTest(t)
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	deployment := func(completedAt time.Time) deploymentAttempt {
+		return deploymentAttempt{
+			Environment: defaultEnvironment,
+			CreatedAt:   completedAt.Add(-10 * time.Minute),
+			CompletedAt: completedAt,
+			Successful:  true,
+		}
+	}
+	calculator := newCalculator(&fakeProvider{attempts: []deploymentAttempt{
+		deployment(from.Add(12 * time.Hour)),
+		deployment(from.Add(36 * time.Hour)),
+		deployment(from.Add(60 * time.Hour)),
+	}})
+
+	var out bytes.Buffer
+	if code := runBackfill(calculator, "acme/api", "main", from, from.AddDate(0, 0, 1), &out); code != 0 {
+		t.Fatalf("exit code = %d, want 0", code)
+	}
+
+	decoder := json.NewDecoder(&out)
+	for i, want := range []int{1, 2} {
+		var metrics DoraMetrics
+		if err := decoder.Decode(&metrics); err != nil {
+			t.Fatalf("snapshot %d: %v", i, err)
+		}
+		if asOf := from.AddDate(0, 0, i+1); !metrics.ComputedAt.Equal(asOf) {
+			t.Errorf("snapshot %d: ComputedAt = %s, want %s", i, metrics.ComputedAt, asOf)
+		}
+		if metrics.SuccessfulDeployments != want {
+			t.Errorf("snapshot %d: SuccessfulDeployments = %d, want %d", i, metrics.SuccessfulDeployments, want)
+		}
+	}
+	if decoder.More() {
+		t.Error("got more than one snapshot per day")
+	}
+}
+
+// rateLimitedProvider fails its first ListDeploymentAttempts with a rate
+// limit error.
+type rateLimitedProvider struct {
+	fakeProvider
+	calls int
+}
+
+func (p *rateLimitedProvider) ListDeploymentAttempts(repoFullName string, branch string, since time.Time) ([]deploymentAttempt, error) {
+	p.calls++
+	if p.calls == 1 {
+		return nil, &partialListError{err: &github.RateLimitError{}}
+	}
+	return p.fakeProvider.ListDeploymentAttempts(repoFullName, branch, since)
+}
+
+func TestAsOfProviderRetriesRateLimitedRequests(t *testing.T) {
+	now := time.Now()
+	inner := &rateLimitedProvider{fakeProvider: fakeProvider{attempts: []deploymentAttempt{
+		{CreatedAt: now.Add(-2 * time.Hour), CompletedAt: now.Add(-time.Hour)},
+		{CreatedAt: now.Add(-time.Hour), CompletedAt: now.Add(time.Hour)},
+	}}}
+	var waits []time.Duration
+	provider := &asOfProvider{Provider: inner, until: now, sleep: func(d time.Duration) { waits = append(waits, d) }}
+
+	attempts, err := provider.ListDeploymentAttempts("acme/api", "main", now.Add(-24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(waits) != 1 || waits[0] != defaultBackfillBackoff {
+		t.Errorf("waits = %v, want one of %s", waits, defaultBackfillBackoff)
+	}
+	if len(attempts) != 1 {
+		t.Errorf("got %d attempts, want only the one completed by until", len(attempts))
+	}
+}
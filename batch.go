@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+)
+
+const (
+	defaultBatchConcurrency = 4
+	maxBatchItems           = 100
+)
+
+type batchRequestItem struct {
+	Repo   string `json:"repo"`
+	Branch string `json:"branch"`
+}
+
+type batchResponseItem struct {
+	Repo    string       `json:"repo"`
+	Branch  string       `json:"branch"`
+	Metrics *DoraMetrics `json:"metrics,omitempty"`
+	Error   string       `json:"error,omitempty"`
+}
+
+// newBatchHandler serves POST /metrics/dora/batch. It computes DORA metrics
+// for every {repo, branch} in the request body using at most concurrency
+// workers, and returns the results in request order.
+func newBatchHandler(calculator *Calculator, concurrency int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var items []batchRequestItem
+		if err := json.NewDecoder(r.Body).Decode(&items); err != nil {
+			log.Printf("Error decoding batch request: %v", err)
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if len(items) > maxBatchItems {
+			http.Error(w, "Too many items in batch request", http.StatusBadRequest)
+			return
+		}
+
+		results := make([]batchResponseItem, len(items))
+		jobs := make(chan int)
+		var wg sync.WaitGroup
+		for i := 0; i < concurrency; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for idx := range jobs {
+					item := items[idx]
+					result := batchResponseItem{Repo: item.Repo, Branch: item.Branch}
+					metrics, err := calculator.Compute(r.Context(), item.Repo, item.Branch)
+					if err != nil {
+						result.Error = err.Error()
+					} else {
+						result.Metrics = metrics
+					}
+					results[idx] = result
+				}
+			}()
+		}
+		for i := range items {
+			jobs <- i
+		}
+		close(jobs)
+		wg.Wait()
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(results); err != nil {
+			log.Printf("Error encoding batch response to JSON: %v", err)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	// The alpine runtime image ships no zoneinfo for MTTR_TIMEZONE.
+	_ "time/tzdata"
+)
+
+// businessHours is the working-time window restore times are measured in
+// when MTTR_BUSINESS_HOURS is set: Monday to Friday, from Start to End in
+// Location, except on Holidays.
+type businessHours struct {
+	Location *time.Location
+	// Start and End are wall clock times of day, as durations since midnight.
+	Start    time.Duration
+	End      time.Duration
+	Holidays map[string]bool
+}
+
+// parseBusinessHours parses a window such as "09:00-17:00".
+func parseBusinessHours(window string, timezone string, holidays string) (*businessHours, error) {
+	startText, endText, ok := strings.Cut(window, "-")
+	if !ok {
+		return nil, fmt.Errorf("expected HH:MM-HH:MM, got %q", window)
+	}
+	start, err := parseClock(startText)
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseClock(endText)
+	if err != nil {
+		return nil, err
+	}
+	if end <= start {
+		return nil, fmt.Errorf("window %q ends before it starts", window)
+	}
+
+	location := time.UTC
+	if timezone != "" {
+		if location, err = time.LoadLocation(timezone); err != nil {
+			return nil, fmt.Errorf("invalid timezone %q: %w", timezone, err)
+		}
+	}
+
+	hours := &businessHours{Location: location, Start: start, End: end, Holidays: make(map[string]bool)}
+	for _, holiday := range strings.Split(holidays, ",") {
+		if holiday = strings.TrimSpace(holiday); holiday == "" {
+			continue
+		}
+		if _, err := time.Parse(time.DateOnly, holiday); err != nil {
+			return nil, fmt.Errorf("invalid holiday %q: expected YYYY-MM-DD", holiday)
+		}
+		hours.Holidays[holiday] = true
+	}
+	return hours, nil
+}
+
+func parseClock(clock string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(clock))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q: expected HH:MM", clock)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// atClock returns the time of day clock on day, by the wall clock of day's
+// location. Adding clock to midnight would be an hour off on the days clocks
+// change for daylight saving time.
+func atClock(day time.Time, clock time.Duration) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), int(clock/time.Hour), int(clock%time.Hour/time.Minute), 0, 0, day.Location())
+}
+
+// between returns the working time between from and to.
+func (b *businessHours) between(from time.Time, to time.Time) time.Duration {
+	from, to = from.In(b.Location), to.In(b.Location)
+	var total time.Duration
+	for day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, b.Location); day.Before(to); day = day.AddDate(0, 0, 1) {
+		if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday || b.Holidays[day.Format(time.DateOnly)] {
+			continue
+		}
+		start, end := atClock(day, b.Start), atClock(day, b.End)
+		if from.After(start) {
+			start = from
+		}
+		if to.Before(end) {
+			end = to
+		}
+		if end.After(start) {
+			total += end.Sub(start)
+		}
+	}
+	return total
+}
+
+// restoreDuration returns how long a restore from start to end took, counting
+// only business hours when MTTR_BUSINESS_HOURS is set.
+func restoreDuration(start time.Time, end time.Time) time.Duration {
+	if cfg.BusinessHours == nil {
+		return end.Sub(start)
+	}
+	return cfg.BusinessHours.between(start, end)
+}
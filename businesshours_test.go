@@ -0,0 +1,22 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBusinessHoursBetweenOnDaylightSavingDay(t *testing.T) {
+	// Tehran's clocks went forward from 00:00 to 01:00 on Tuesday
+	// 2022-03-22, so that working day starts 8 hours after midnight.
+	location, err := time.LoadLocation("Asia/Tehran")
+	if err != nil {
+		t.Skip(err)
+	}
+	hours := &businessHours{Location: location, Start: 9 * time.Hour, End: 17 * time.Hour, Holidays: map[string]bool{}}
+
+	from := time.Date(2022, 3, 22, 8, 0, 0, 0, location)
+	to := time.Date(2022, 3, 22, 12, 0, 0, 0, location)
+	if got := hours.between(from, to); got != 3*time.Hour {
+		t.Errorf("between = %s, want 3h", got)
+	}
+}
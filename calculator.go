@@ -0,0 +1,31 @@
+package main
+
+import "time"
+
+// Calculator computes DORA metrics from a Provider. It is constructed once in
+// main and shared by everything that computes metrics: webhooks, the refresh
+// loop, the batch and recompute endpoints and the gRPC service. Settings such
+// as the windows and filters are read from cfg.
+type Calculator struct {
+	provider Provider
+	// now is the clock the metric windows are placed with, so that tests
+	// can freeze time and check exactly which runs fall inside a window, and
+	// so that -backfill can compute the metrics as they stood at the end of
+	// a past day.
+	now func() time.Time
+	// pagerDuty lists the incidents of repos whose restore time source is
+	// "pagerduty". Nil unless PAGERDUTY_TOKEN is set.
+	pagerDuty *pagerDutyClient
+}
+
+func newCalculator(provider Provider) *Calculator {
+	return &Calculator{provider: provider, now: time.Now}
+}
+
+// withProvider returns a Calculator reading from provider with the same
+// clock and incident sources, e.g. one limited to a monorepo service.
+func (c *Calculator) withProvider(provider Provider) *Calculator {
+	calculator := *c
+	calculator.provider = provider
+	return &calculator
+}
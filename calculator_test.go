@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestComputeUsesCalculatorClock(t *testing.T) {
+	setupWebhookTest(t)
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	deployment := func(ago time.Duration, successful bool) deploymentAttempt {
+		return deploymentAttempt{
+			Environment: defaultEnvironment,
+			CreatedAt:   now.Add(-ago - 10*time.Minute),
+			CompletedAt: now.Add(-ago),
+			Successful:  successful,
+		}
+	}
+	window := time.Duration(cfg.DeploymentFrequencyWindowDays) * 24 * time.Hour
+	provider := &fakeProvider{attempts: []deploymentAttempt{
+		deployment(48*time.Hour, true),
+		deployment(240*time.Hour, false),
+		// Just outside the window ending at now.
+		deployment(window+time.Hour, true),
+	}}
+	calculator := newCalculator(provider)
+	calculator.now = func() time.Time { return now }
+
+	metrics, err := calculator.Compute(context.Background(), "acme/api", "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !metrics.ComputedAt.Equal(now) {
+		t.Errorf("ComputedAt = %s, want %s", metrics.ComputedAt, now)
+	}
+	if metrics.SuccessfulDeployments != 1 || metrics.FailedDeployments != 1 {
+		t.Errorf("deployments = %d successful, %d failed, want 1 and 1", metrics.SuccessfulDeployments, metrics.FailedDeployments)
+	}
+	if want := 2 / float64(cfg.DeploymentFrequencyWindowDays); metrics.DeploymentFrequency != want {
+		t.Errorf("DeploymentFrequency = %f, want %f", metrics.DeploymentFrequency, want)
+	}
+	if want := (48 * time.Hour).Seconds(); metrics.SecondsSinceLastDeployment != want {
+		t.Errorf("SecondsSinceLastDeployment = %f, want %f", metrics.SecondsSinceLastDeployment, want)
+	}
+}
+
+func TestRecordMergedPullRequestUsesCalculatorClock(t *testing.T) {
+	setupWebhookTest(t)
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	calculator := newCalculator(&fakeProvider{})
+	calculator.now = func() time.Time { return now }
+	reviews = newReviewTracker()
+
+	old := now.Add(-reviewWindow() - time.Hour)
+	recordMergedPullRequest(calculator, "acme/api", "main", old.Add(-10*time.Hour), old)
+	recordMergedPullRequest(calculator, "acme/api", "main", now.Add(-3*time.Hour), now.Add(-time.Hour))
+
+	// The first merge is out of the window ending at now.
+	if got := reviews.record(seriesKey{Repo: "acme/api", Branch: "main"}, now.Add(-4*time.Hour), now, now); got != 180 {
+		t.Errorf("review lead time = %f minutes, want 180", got)
+	}
+}
+
+func TestRefreshReviewLeadTimesDropsExpiredMerges(t *testing.T) {
+	setupWebhookTest(t)
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	calculator := newCalculator(&fakeProvider{})
+	calculator.now = func() time.Time { return now }
+	reviews = newReviewTracker()
+
+	recordMergedPullRequest(calculator, "acme/api", "main", now.Add(-3*time.Hour), now.Add(-time.Hour))
+	key := seriesKey{Repo: "acme/api", Branch: "main"}
+	if _, ok := reviews.average(key, now); !ok {
+		t.Fatal("merge within the window not tracked")
+	}
+
+	now = now.Add(reviewWindow())
+	refreshReviewLeadTimes(calculator)
+	if _, ok := reviews.average(key, now); ok {
+		t.Error("merge out of the window still tracked")
+	}
+	if reviewLeadTime.DeleteLabelValues(key.Branch, key.Repo) {
+		t.Error("review lead time series of the expired merge not removed")
+	}
+}
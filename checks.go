@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/go-github/v45/github"
+)
+
+// listDeploymentAttemptsFromCheckRuns treats completed check runs named
+// cfg.DeploymentCheckName, or matching cfg.DeploymentMatchRegex, on the
+// commits of branch as deployments. This
+// supports external CI systems that report back through the Checks API rather
+// than running GitHub Actions workflows.
+func (p *githubProvider) listDeploymentAttemptsFromCheckRuns(repoFullName string, branch string, since time.Time) ([]deploymentAttempt, error) {
+	ctx := context.Background()
+	owner, repo := getOwner(repoFullName), getRepo(repoFullName)
+
+	// Check runs can only be listed per ref, so walk the commits pushed to
+	// the branch within the window.
+	countGitHubCall("commits")
+	commits, _, err := p.client.Repositories.ListCommits(ctx, owner, repo, &github.CommitsListOptions{
+		SHA:         branch,
+		Since:       since,
+		ListOptions: github.ListOptions{PerPage: 100},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("fetching commits: %w", err)
+	}
+
+	var attempts []deploymentAttempt
+	for _, commit := range commits {
+		opts := &github.ListCheckRunsOptions{
+			Status:      github.String("completed"),
+			Filter:      github.String("all"),
+			ListOptions: github.ListOptions{PerPage: 100},
+		}
+		if cfg.DeploymentCheckName != "" {
+			opts.CheckName = github.String(cfg.DeploymentCheckName)
+		}
+		countGitHubCall("check_runs")
+		checkRuns, _, err := p.client.Checks.ListCheckRunsForRef(ctx, owner, repo, commit.GetSHA(), opts)
+		if err != nil {
+			return nil, fmt.Errorf("fetching check runs for %s: %w", commit.GetSHA(), err)
+		}
+
+		for _, checkRun := range checkRuns.CheckRuns {
+			if !hasRunTimes(repoFullName, "check run", checkRun.GetID(), checkRun.GetStartedAt().Time, checkRun.GetCompletedAt().Time) {
+				continue
+			}
+			if !deploymentTime(checkRun.GetStartedAt().Time, checkRun.GetCompletedAt().Time).After(since) {
+				continue
+			}
+			match, ok := matchDeploymentName(checkRun.GetName())
+			if !ok {
+				continue
+			}
+			class := classifyConclusion(checkRun.GetConclusion())
+			if class == conclusionIgnore {
+				continue
+			}
+			environment := match.Environment
+			if environment == "" {
+				environment = defaultEnvironment
+			}
+			attempts = append(attempts, deploymentAttempt{
+				Environment: environment,
+				Version:     match.Version,
+				HeadSHA:     checkRun.GetHeadSHA(),
+				CreatedAt:   checkRun.GetStartedAt().Time,
+				CompletedAt: checkRun.GetCompletedAt().Time,
+				Successful:  class == conclusionSuccess,
+			})
+		}
+	}
+	return attempts, nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// conclusionClass is how a run conclusion reported by GitHub is counted.
+type conclusionClass string
+
+const (
+	conclusionSuccess conclusionClass = "success"
+	conclusionFailure conclusionClass = "failure"
+	// conclusionIgnore excludes the run from every metric.
+	conclusionIgnore conclusionClass = "ignore"
+)
+
+// defaultConclusionClasses covers the conclusions GitHub reports for workflow
+// runs and check runs. Conclusions not listed here are ignored.
+var defaultConclusionClasses = map[string]conclusionClass{
+	"success":         conclusionSuccess,
+	"failure":         conclusionFailure,
+	"timed_out":       conclusionFailure,
+	"startup_failure": conclusionFailure,
+	"cancelled":       conclusionIgnore,
+	"skipped":         conclusionIgnore,
+	"neutral":         conclusionIgnore,
+	"action_required": conclusionIgnore,
+	"stale":           conclusionIgnore,
+}
+
+// classifyConclusion maps a workflow run or check run conclusion to how it is
+// counted, using the configured CONCLUSION_CLASSES.
+func classifyConclusion(conclusion string) conclusionClass {
+	if class, ok := cfg.ConclusionClasses[conclusion]; ok {
+		return class
+	}
+	return conclusionIgnore
+}
+
+// parseConclusionClasses applies a comma-separated list of conclusion=class
+// overrides on top of the defaults.
+func parseConclusionClasses(list string) (map[string]conclusionClass, error) {
+	classes := make(map[string]conclusionClass, len(defaultConclusionClasses))
+	for conclusion, class := range defaultConclusionClasses {
+		classes[conclusion] = class
+	}
+
+	overrides, err := parseKeyValueList(list)
+	if err != nil {
+		return nil, err
+	}
+	for conclusion, class := range overrides {
+		switch c := conclusionClass(strings.ToLower(class)); c {
+		case conclusionSuccess, conclusionFailure, conclusionIgnore:
+			classes[conclusion] = c
+		default:
+			return nil, fmt.Errorf("invalid class %q for conclusion %q: must be success, failure or ignore", class, conclusion)
+		}
+	}
+	return classes, nil
+}
+
+// withSuccessConclusions returns a copy of classes in which exactly the
+// conclusions in the comma-separated list count as successful deployments.
+// Conclusions that were successful but are not listed are ignored.
+func withSuccessConclusions(classes map[string]conclusionClass, list string) (map[string]conclusionClass, error) {
+	result := make(map[string]conclusionClass, len(classes))
+	for conclusion, class := range classes {
+		if class == conclusionSuccess {
+			class = conclusionIgnore
+		}
+		result[conclusion] = class
+	}
+
+	listed := 0
+	for _, conclusion := range strings.Split(list, ",") {
+		if conclusion = strings.TrimSpace(conclusion); conclusion != "" {
+			result[conclusion] = conclusionSuccess
+			listed++
+		}
+	}
+	if listed == 0 {
+		return nil, fmt.Errorf("expected at least one conclusion")
+	}
+	return result, nil
+}
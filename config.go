@@ -0,0 +1,870 @@
+package main
+
+import (
+	"fmt"
+	"net/netip"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	deploymentSourceWorkflowRuns = "workflow_runs"
+	deploymentSourceDeployments  = "deployments"
+	deploymentSourceChecks       = "checks"
+	deploymentSourceReleases     = "releases"
+	deploymentSourceStatuses     = "statuses"
+)
+
+const (
+	restoreTimeSourceIssues         = "issues"
+	restoreTimeSourceDeployments    = "deployments"
+	restoreTimeSourceDeployRecovery = "deploy_recovery"
+	restoreTimeSourcePagerDuty      = "pagerduty"
+	restoreTimeSourceDisabled       = "disabled"
+)
+
+const defaultWindowDays = 30
+
+// config holds the calculation settings resolved from the environment.
+type config struct {
+	// SCMProvider selects the source control system metrics are read from.
+	SCMProvider string
+	// GitLabURL is the base URL of the GitLab instance when SCMProvider is
+	// "gitlab".
+	GitLabURL string
+	// PaginationConcurrency is how many pages of a multi-page listing are
+	// fetched at once.
+	PaginationConcurrency int
+	// GitHubRateLimitMetrics exposes the rate limit GitHub reports on its
+	// API responses.
+	GitHubRateLimitMetrics bool
+	// WebhookIPAllowlist restricts webhook deliveries to these source
+	// ranges. Empty accepts deliveries from anywhere.
+	WebhookIPAllowlist []netip.Prefix
+	// WebhookTrustedProxies are the proxies whose X-Forwarded-For header is
+	// trusted when determining the source of a webhook delivery.
+	WebhookTrustedProxies []netip.Prefix
+	// MetricNamespace and MetricSubsystem prefix the names of the
+	// Prometheus metrics.
+	MetricNamespace string
+	MetricSubsystem string
+	// EnabledMetrics holds the headline metrics that are calculated and
+	// published. Nil enables all of them.
+	EnabledMetrics map[string]bool
+	// WindowDays is how many days back the metrics are calculated over. The
+	// per-metric windows fall back to it.
+	WindowDays                    int
+	DeploymentFrequencyWindowDays int
+	LeadTimeWindowDays            int
+	RestoreTimeWindowDays         int
+	ChangeFailureRateWindowDays   int
+	// The minimum samples are the fewest lead time samples, incidents and
+	// deployment attempts a metric is published from. 0 publishes any.
+	LeadTimeMinSamples          int
+	RestoreTimeMinSamples       int
+	ChangeFailureRateMinSamples int
+	// AsyncWebhooks answers webhooks with 202 Accepted and recomputes in the
+	// background, using a queue of WebhookQueueSize repo/branches drained by
+	// WebhookQueueWorkers workers. Failed recomputes are retried up to
+	// RecomputeRetries times.
+	AsyncWebhooks       bool
+	WebhookQueueSize    int
+	WebhookQueueWorkers int
+	RecomputeRetries    int
+	// WebhookAuditLog is the file every webhook delivery is recorded in as
+	// a JSON line. Empty disables the audit log.
+	WebhookAuditLog string
+	// DeliveryCacheSize is how many webhook delivery IDs are remembered to
+	// reject duplicates, for up to DeliveryCacheTTL. 0 disables the check.
+	DeliveryCacheSize int
+	DeliveryCacheTTL  time.Duration
+	// GRPCAddr, if set, is where the gRPC DoraService is served.
+	GRPCAddr string
+	// EnablePprof serves the net/http/pprof handlers on PprofAddr.
+	EnablePprof bool
+	PprofAddr   string
+	// MetricsSinks names the sinks computed metrics are published to.
+	MetricsSinks []string
+	// MetricsSinkURL is where the "http" sink posts metrics to.
+	MetricsSinkURL string
+	// BranchRefPrefixes are stripped from pushed refs to get the branch.
+	BranchRefPrefixes []string
+	// ProductionBranch overrides the repository's default branch as the
+	// branch deployments to production are made from.
+	ProductionBranch string
+	// Org is the GitHub organization whose repositories are discovered and
+	// refreshed. Repositories must have one of OrgTopics and be written in
+	// one of OrgLanguages (lower case) when these are set.
+	Org                string
+	OrgTopics          map[string]bool
+	OrgLanguages       map[string]bool
+	OrgIncludeArchived bool
+	// ProductionBranchOnly skips webhook events for branches other than the
+	// production branch.
+	ProductionBranchOnly bool
+	// MinRecomputeInterval is the minimum time between two recalculations
+	// of the same repo/branch. Requests within it get the previous result.
+	MinRecomputeInterval time.Duration
+	// AggregateBranches also publishes a series across all branches of a
+	// repository whenever one of its branches is recomputed.
+	AggregateBranches bool
+	// DeploymentSource selects where deployments are read from: GitHub
+	// Actions workflow runs, the Deployments API, check runs, releases or
+	// commit statuses.
+	DeploymentSource string
+	// DeploymentCheckName is the check run name that marks a deployment when
+	// DeploymentSource is "checks".
+	DeploymentCheckName string
+	// DeploymentWorkflowFile, if set, is the file name of the only GitHub
+	// workflow whose runs are listed, e.g. "deploy.yml".
+	DeploymentWorkflowFile string
+	// DeploymentMatchRegex, if set, limits deployments to the workflow and
+	// check runs whose name it matches. Its "environment" and "version"
+	// named groups label the deployment.
+	DeploymentMatchRegex *regexp.Regexp
+	// DeploymentStatusContext is the commit status context that marks a
+	// deployment when DeploymentSource is "statuses".
+	DeploymentStatusContext string
+	// DeploymentWindowBasis selects whether runs fall in a window by when
+	// they were created or when they completed.
+	DeploymentWindowBasis string
+	// DeploymentTriggerEvents holds the events whose workflow runs or
+	// pipelines count as deployments. A nil map accepts every event.
+	DeploymentTriggerEvents map[string]bool
+	// ExcludeInactiveWorkflows ignores runs of workflows that have been
+	// deleted or disabled.
+	ExcludeInactiveWorkflows bool
+	// DeploymentManifestArtifact is the name of the artifact, uploaded by
+	// deploy workflows, whose DeploymentManifestFile records the deployed
+	// environment and version. Empty disables reading manifests.
+	DeploymentManifestArtifact string
+	DeploymentManifestFile     string
+	// WorkflowEnvironments maps workflow names to the environment they deploy
+	// to when DeploymentSource is "workflow_runs".
+	WorkflowEnvironments map[string]string
+	// DeploymentsByActor also counts deployments per triggering user, or per
+	// team when the user is in DeploymentActorTeams.
+	DeploymentsByActor   bool
+	DeploymentActorTeams map[string]string
+	// DeploymentActorAllowlist limits the actors counted by name; the others
+	// are counted as "other". Nil allows every actor.
+	DeploymentActorAllowlist map[string]bool
+	// DeploymentActorLimit is the most actors counted by name per repo and
+	// branch; the least active ones beyond it are counted as "other".
+	DeploymentActorLimit int
+	// AdjustFrequencyForNewRepos averages deployment frequency over the age
+	// of repositories younger than the window instead of the full window.
+	AdjustFrequencyForNewRepos bool
+	// DeploymentExemplars counts each deployment attempt in
+	// deployments_observed_total with its commit as an OpenMetrics exemplar.
+	DeploymentExemplars bool
+	// FrequencyHalfLife, if positive, also calculates a deployment
+	// frequency in which each deployment's weight halves every
+	// FrequencyHalfLife of age.
+	FrequencyHalfLife time.Duration
+	// FrequencyCompleteDays ends the deployment frequency window at the
+	// start of the current UTC day, leaving the partial day out of both the
+	// count and the number of days.
+	FrequencyCompleteDays bool
+	// ConclusionClasses maps workflow run and check run conclusions to
+	// whether they count as a successful deployment, a failed one, or are
+	// ignored.
+	ConclusionClasses map[string]conclusionClass
+	// CFRAsPercent publishes the change failure rate as a percentage
+	// instead of a 0-1 ratio.
+	CFRAsPercent bool
+	// ExcludeWorkflows holds the names of workflows whose runs are left out
+	// of the change failure rate entirely.
+	ExcludeWorkflows map[string]bool
+	// LeadTimeDistributions holds the kinds of Prometheus distribution,
+	// "histogram" and "summary", that each deployment's lead time is
+	// observed into.
+	LeadTimeDistributions map[string]bool
+	// LeadTimeMode selects where each lead time starts: at run creation, at
+	// the run's head commit, at the oldest commit shipped by the run, or at
+	// the head commit of the previous successful run.
+	LeadTimeMode string
+	// ProductionPaths are the directories and path.Match patterns a
+	// deployment's commit must change a file in to count toward deployment
+	// frequency and lead time. Empty counts every deployment.
+	ProductionPaths []string
+	// HotfixCommitPrefixes are head commit message prefixes that mark a
+	// deployment as a hotfix.
+	HotfixCommitPrefixes []string
+	// HotfixBranchPattern is a path.Match pattern for the branches, merged
+	// in the head commit, that mark a deployment as a hotfix.
+	HotfixBranchPattern string
+	// HotfixLabels are pull or merge request labels that mark a deployment
+	// as a hotfix.
+	HotfixLabels map[string]bool
+	// PullRequestMetrics also calculates merge frequency and open-to-merge
+	// lead time of merged pull requests.
+	PullRequestMetrics bool
+	// RestoreTimeSource selects how Time to Restore Service is measured:
+	// from closed issues labeled "incident", from failed-then-succeeded
+	// deployments via the Deployments API or from the DEPLOYMENT_SOURCE
+	// attempts, from resolved PagerDuty incidents, or not at all.
+	RestoreTimeSource string
+	// MonorepoServices are the services whose metrics are also calculated
+	// from the deployments that changed their directory.
+	MonorepoServices []monorepoService
+	// RepoRestoreTimeSources overrides RestoreTimeSource for the repos it
+	// holds, keyed by lowercased owner/name.
+	RepoRestoreTimeSources map[string]string
+	// PagerDutyURL is the base URL of the PagerDuty REST API.
+	PagerDutyURL string
+	// PagerDutyServices maps lowercased owner/name to the ID of the
+	// PagerDuty service whose incidents are the repo's when its restore time
+	// source is "pagerduty".
+	PagerDutyServices map[string]string
+	// RestoreTimeEnvironment is the deployment environment used when
+	// RestoreTimeSource is "deployments".
+	RestoreTimeEnvironment string
+	// ResolutionLabel, if set, marks an incident as resolved when it is
+	// applied, which may be before the issue is closed.
+	ResolutionLabel string
+	// IncludeOpenIncidents also reports the incidents that are still open.
+	IncludeOpenIncidents bool
+	// BusinessHours restricts restore times to working hours. Nil counts
+	// every hour.
+	BusinessHours *businessHours
+	// SeverityWeights maps incident severity labels to the weight of their
+	// restore time in the Time to Restore Service mean.
+	SeverityWeights map[string]float64
+	// CompositeScoreWeights weights each headline metric in the composite
+	// score. Unset metrics have weight 1.
+	CompositeScoreWeights map[string]float64
+}
+
+// cfg is populated by loadConfig at startup.
+var cfg = config{
+	SCMProvider:             scmProviderGitHub,
+	GitLabURL:               "https://gitlab.com",
+	PaginationConcurrency:   defaultPaginationConcurrency,
+	MetricNamespace:         "dora",
+	PprofAddr:               defaultPprofAddr,
+	WebhookQueueSize:        defaultWebhookQueueSize,
+	WebhookQueueWorkers:     defaultWebhookQueueWorkers,
+	RecomputeRetries:        defaultRecomputeRetries,
+	DeploymentSource:        deploymentSourceWorkflowRuns,
+	DeploymentWindowBasis:   windowBasisCompleted,
+	DeploymentTriggerEvents: map[string]bool{"push": true},
+	ConclusionClasses:       defaultConclusionClasses,
+	LeadTimeMode:            leadTimeModeRunDuration,
+	RestoreTimeSource:       restoreTimeSourceIssues,
+	RestoreTimeEnvironment:  "production",
+	PagerDutyURL:            "https://api.pagerduty.com",
+}
+
+func loadConfig() error {
+	if v := os.Getenv("SCM_PROVIDER"); v != "" {
+		switch v {
+		case scmProviderGitHub, scmProviderGitLab:
+			cfg.SCMProvider = v
+		default:
+			return fmt.Errorf("invalid SCM_PROVIDER %q: must be %q or %q", v, scmProviderGitHub, scmProviderGitLab)
+		}
+	}
+	if v := os.Getenv("GITLAB_URL"); v != "" {
+		cfg.GitLabURL = strings.TrimSuffix(v, "/")
+	}
+	if v := os.Getenv("PAGINATION_CONCURRENCY"); v != "" {
+		concurrency, err := strconv.Atoi(v)
+		if err != nil || concurrency <= 0 {
+			return fmt.Errorf("invalid PAGINATION_CONCURRENCY %q", v)
+		}
+		cfg.PaginationConcurrency = concurrency
+	}
+	if v := os.Getenv("GITHUB_RATE_LIMIT_METRICS"); v != "" {
+		enable, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid GITHUB_RATE_LIMIT_METRICS %q: %w", v, err)
+		}
+		cfg.GitHubRateLimitMetrics = enable
+	}
+	if v := os.Getenv("WEBHOOK_IP_ALLOWLIST"); v != "" {
+		allowlist, err := parsePrefixList(v)
+		if err != nil {
+			return fmt.Errorf("invalid WEBHOOK_IP_ALLOWLIST: %w", err)
+		}
+		cfg.WebhookIPAllowlist = allowlist
+	}
+	if v := os.Getenv("WEBHOOK_TRUSTED_PROXIES"); v != "" {
+		proxies, err := parsePrefixList(v)
+		if err != nil {
+			return fmt.Errorf("invalid WEBHOOK_TRUSTED_PROXIES: %w", err)
+		}
+		cfg.WebhookTrustedProxies = proxies
+	}
+	if v, ok := os.LookupEnv("METRIC_NAMESPACE"); ok {
+		cfg.MetricNamespace = v
+	}
+	cfg.MetricSubsystem = os.Getenv("METRIC_SUBSYSTEM")
+	if v := os.Getenv("ENABLED_METRICS"); v != "" {
+		enabled, err := parseEnabledMetrics(v)
+		if err != nil {
+			return fmt.Errorf("invalid ENABLED_METRICS: %w", err)
+		}
+		cfg.EnabledMetrics = enabled
+	}
+	windowDays, err := parseWindowDays("WINDOW_DAYS", defaultWindowDays)
+	if err != nil {
+		return err
+	}
+	cfg.WindowDays = windowDays
+	for name, days := range map[string]*int{
+		"DF_WINDOW_DAYS":        &cfg.DeploymentFrequencyWindowDays,
+		"LEAD_TIME_WINDOW_DAYS": &cfg.LeadTimeWindowDays,
+		"MTTR_WINDOW_DAYS":      &cfg.RestoreTimeWindowDays,
+		"CFR_WINDOW_DAYS":       &cfg.ChangeFailureRateWindowDays,
+	} {
+		if *days, err = parseWindowDays(name, cfg.WindowDays); err != nil {
+			return err
+		}
+	}
+	for name, value := range map[string]*int{
+		"LEAD_TIME_MIN_SAMPLES": &cfg.LeadTimeMinSamples,
+		"MTTR_MIN_SAMPLES":      &cfg.RestoreTimeMinSamples,
+		"CFR_MIN_SAMPLES":       &cfg.ChangeFailureRateMinSamples,
+	} {
+		if v := os.Getenv(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return fmt.Errorf("invalid %s %q", name, v)
+			}
+			*value = n
+		}
+	}
+	if v := os.Getenv("ASYNC_WEBHOOKS"); v != "" {
+		async, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid ASYNC_WEBHOOKS %q: %w", v, err)
+		}
+		cfg.AsyncWebhooks = async
+	}
+	for name, value := range map[string]*int{
+		"WEBHOOK_QUEUE_SIZE":    &cfg.WebhookQueueSize,
+		"WEBHOOK_QUEUE_WORKERS": &cfg.WebhookQueueWorkers,
+	} {
+		if v := os.Getenv(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				return fmt.Errorf("invalid %s %q", name, v)
+			}
+			*value = n
+		}
+	}
+	if v := os.Getenv("RECOMPUTE_RETRIES"); v != "" {
+		retries, err := strconv.Atoi(v)
+		if err != nil || retries < 0 {
+			return fmt.Errorf("invalid RECOMPUTE_RETRIES %q", v)
+		}
+		cfg.RecomputeRetries = retries
+	}
+	cfg.WebhookAuditLog = os.Getenv("WEBHOOK_AUDIT_LOG")
+	cfg.DeliveryCacheSize = defaultDeliveryCacheSize
+	if v := os.Getenv("WEBHOOK_DELIVERY_CACHE_SIZE"); v != "" {
+		size, err := strconv.Atoi(v)
+		if err != nil || size < 0 {
+			return fmt.Errorf("invalid WEBHOOK_DELIVERY_CACHE_SIZE %q", v)
+		}
+		cfg.DeliveryCacheSize = size
+	}
+	cfg.DeliveryCacheTTL = defaultDeliveryCacheTTL
+	if v := os.Getenv("WEBHOOK_DELIVERY_CACHE_TTL"); v != "" {
+		ttl, err := time.ParseDuration(v)
+		if err != nil || ttl <= 0 {
+			return fmt.Errorf("invalid WEBHOOK_DELIVERY_CACHE_TTL %q", v)
+		}
+		cfg.DeliveryCacheTTL = ttl
+	}
+	cfg.GRPCAddr = os.Getenv("GRPC_ADDR")
+	if v := os.Getenv("ENABLE_PPROF"); v != "" {
+		enable, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid ENABLE_PPROF %q: %w", v, err)
+		}
+		cfg.EnablePprof = enable
+	}
+	if v := os.Getenv("PPROF_ADDR"); v != "" {
+		cfg.PprofAddr = v
+	}
+	cfg.MetricsSinks = []string{sinkPrometheus}
+	if v := os.Getenv("METRICS_SINKS"); v != "" {
+		cfg.MetricsSinks = nil
+		for _, sink := range strings.Split(v, ",") {
+			sink = strings.TrimSpace(sink)
+			switch sink {
+			case "":
+				continue
+			case sinkPrometheus, sinkHTTP, sinkLineProtocol:
+				cfg.MetricsSinks = append(cfg.MetricsSinks, sink)
+			default:
+				return fmt.Errorf("invalid METRICS_SINKS: unknown sink %q", sink)
+			}
+		}
+	}
+	if v := os.Getenv("STDOUT_LINE_PROTOCOL"); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid STDOUT_LINE_PROTOCOL %q: %w", v, err)
+		}
+		if enabled && !slices.Contains(cfg.MetricsSinks, sinkLineProtocol) {
+			cfg.MetricsSinks = append(cfg.MetricsSinks, sinkLineProtocol)
+		}
+	}
+	cfg.MetricsSinkURL = os.Getenv("METRICS_SINK_URL")
+	if slices.Contains(cfg.MetricsSinks, sinkHTTP) && cfg.MetricsSinkURL == "" {
+		return fmt.Errorf("METRICS_SINK_URL is required when METRICS_SINKS includes %q", sinkHTTP)
+	}
+	cfg.BranchRefPrefixes = []string{"refs/heads/"}
+	if v := os.Getenv("BRANCH_REF_PREFIXES"); v != "" {
+		cfg.BranchRefPrefixes = nil
+		for _, prefix := range strings.Split(v, ",") {
+			if prefix = strings.TrimSpace(prefix); prefix != "" {
+				cfg.BranchRefPrefixes = append(cfg.BranchRefPrefixes, prefix)
+			}
+		}
+	}
+	cfg.ProductionBranch = os.Getenv("PRODUCTION_BRANCH")
+	cfg.Org = os.Getenv("ORG")
+	if cfg.Org != "" && cfg.SCMProvider != scmProviderGitHub {
+		return fmt.Errorf("ORG is only supported with SCM_PROVIDER %q", scmProviderGitHub)
+	}
+	if v := os.Getenv("ORG_TOPICS"); v != "" {
+		cfg.OrgTopics = make(map[string]bool)
+		for _, topic := range strings.Split(v, ",") {
+			if topic = strings.TrimSpace(topic); topic != "" {
+				cfg.OrgTopics[topic] = true
+			}
+		}
+	}
+	if v := os.Getenv("ORG_LANGUAGES"); v != "" {
+		cfg.OrgLanguages = make(map[string]bool)
+		for _, language := range strings.Split(v, ",") {
+			if language = strings.TrimSpace(language); language != "" {
+				cfg.OrgLanguages[strings.ToLower(language)] = true
+			}
+		}
+	}
+	if v := os.Getenv("ORG_INCLUDE_ARCHIVED"); v != "" {
+		include, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid ORG_INCLUDE_ARCHIVED %q: %w", v, err)
+		}
+		cfg.OrgIncludeArchived = include
+	}
+	if v := os.Getenv("PRODUCTION_BRANCH_ONLY"); v != "" {
+		only, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid PRODUCTION_BRANCH_ONLY %q: %w", v, err)
+		}
+		cfg.ProductionBranchOnly = only
+	}
+	if v := os.Getenv("MIN_RECOMPUTE_INTERVAL"); v != "" {
+		interval, err := time.ParseDuration(v)
+		if err != nil || interval < 0 {
+			return fmt.Errorf("invalid MIN_RECOMPUTE_INTERVAL %q", v)
+		}
+		cfg.MinRecomputeInterval = interval
+	}
+	if v := os.Getenv("AGGREGATE_BRANCHES"); v != "" {
+		aggregate, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid AGGREGATE_BRANCHES %q: %w", v, err)
+		}
+		cfg.AggregateBranches = aggregate
+	}
+	if v := os.Getenv("DEPLOYMENT_SOURCE"); v != "" {
+		switch v {
+		case deploymentSourceWorkflowRuns, deploymentSourceDeployments, deploymentSourceChecks, deploymentSourceReleases, deploymentSourceStatuses:
+			cfg.DeploymentSource = v
+		default:
+			return fmt.Errorf("invalid DEPLOYMENT_SOURCE %q: must be one of %q, %q, %q, %q or %q", v, deploymentSourceWorkflowRuns, deploymentSourceDeployments, deploymentSourceChecks, deploymentSourceReleases, deploymentSourceStatuses)
+		}
+	}
+	cfg.DeploymentCheckName = os.Getenv("DEPLOYMENT_CHECK_NAME")
+	if v := strings.TrimSpace(os.Getenv("DEPLOYMENT_WORKFLOW_FILE")); v != "" {
+		// The API identifies a workflow by its file name, so a path such as
+		// .github/workflows/deploy.yml is reduced to deploy.yml.
+		cfg.DeploymentWorkflowFile = path.Base(v)
+	}
+	if v := os.Getenv("DEPLOYMENT_MATCH_REGEX"); v != "" {
+		re, err := regexp.Compile(v)
+		if err != nil {
+			return fmt.Errorf("invalid DEPLOYMENT_MATCH_REGEX %q: %w", v, err)
+		}
+		cfg.DeploymentMatchRegex = re
+	}
+	cfg.DeploymentStatusContext = os.Getenv("DEPLOYMENT_STATUS_CONTEXT")
+	if (cfg.DeploymentSource == deploymentSourceChecks || cfg.DeploymentSource == deploymentSourceReleases || cfg.DeploymentSource == deploymentSourceStatuses) && cfg.SCMProvider != scmProviderGitHub {
+		return fmt.Errorf("DEPLOYMENT_SOURCE %q is only supported with SCM_PROVIDER %q", cfg.DeploymentSource, scmProviderGitHub)
+	}
+	if cfg.DeploymentSource == deploymentSourceChecks && cfg.DeploymentCheckName == "" && cfg.DeploymentMatchRegex == nil {
+		return fmt.Errorf("DEPLOYMENT_CHECK_NAME or DEPLOYMENT_MATCH_REGEX must be set when DEPLOYMENT_SOURCE is %q", deploymentSourceChecks)
+	}
+	if cfg.DeploymentSource == deploymentSourceStatuses && cfg.DeploymentStatusContext == "" {
+		return fmt.Errorf("DEPLOYMENT_STATUS_CONTEXT must be set when DEPLOYMENT_SOURCE is %q", deploymentSourceStatuses)
+	}
+	if v := os.Getenv("DEPLOYMENT_WINDOW_BASIS"); v != "" {
+		switch v {
+		case windowBasisCompleted, windowBasisCreated:
+			cfg.DeploymentWindowBasis = v
+		default:
+			return fmt.Errorf("invalid DEPLOYMENT_WINDOW_BASIS %q: must be %q or %q", v, windowBasisCompleted, windowBasisCreated)
+		}
+	}
+	if v := os.Getenv("DEPLOYMENT_TRIGGER_EVENTS"); v != "" {
+		cfg.DeploymentTriggerEvents = nil
+		if strings.TrimSpace(v) != "*" {
+			cfg.DeploymentTriggerEvents = make(map[string]bool)
+			for _, event := range strings.Split(v, ",") {
+				if event = strings.TrimSpace(event); event != "" {
+					cfg.DeploymentTriggerEvents[event] = true
+				}
+			}
+		}
+	}
+	if v := os.Getenv("EXCLUDE_INACTIVE_WORKFLOWS"); v != "" {
+		exclude, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid EXCLUDE_INACTIVE_WORKFLOWS %q: %w", v, err)
+		}
+		cfg.ExcludeInactiveWorkflows = exclude
+	}
+	cfg.DeploymentManifestArtifact = os.Getenv("DEPLOYMENT_MANIFEST_ARTIFACT")
+	cfg.DeploymentManifestFile = defaultDeploymentManifestFile
+	if v := os.Getenv("DEPLOYMENT_MANIFEST_FILE"); v != "" {
+		cfg.DeploymentManifestFile = v
+	}
+	if cfg.DeploymentManifestArtifact != "" && (cfg.SCMProvider != scmProviderGitHub || cfg.DeploymentSource != deploymentSourceWorkflowRuns) {
+		return fmt.Errorf("DEPLOYMENT_MANIFEST_ARTIFACT is only supported with SCM_PROVIDER %q and DEPLOYMENT_SOURCE %q", scmProviderGitHub, deploymentSourceWorkflowRuns)
+	}
+	if v := os.Getenv("WORKFLOW_ENVIRONMENTS"); v != "" {
+		environments, err := parseKeyValueList(v)
+		if err != nil {
+			return fmt.Errorf("invalid WORKFLOW_ENVIRONMENTS: %w", err)
+		}
+		cfg.WorkflowEnvironments = environments
+	}
+	if v := os.Getenv("DEPLOYMENTS_BY_ACTOR"); v != "" {
+		byActor, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid DEPLOYMENTS_BY_ACTOR %q: %w", v, err)
+		}
+		cfg.DeploymentsByActor = byActor
+	}
+	if v := os.Getenv("DEPLOYMENT_ACTOR_TEAMS"); v != "" {
+		teams, err := parseKeyValueList(v)
+		if err != nil {
+			return fmt.Errorf("invalid DEPLOYMENT_ACTOR_TEAMS: %w", err)
+		}
+		cfg.DeploymentActorTeams = teams
+	}
+	if v := os.Getenv("DEPLOYMENT_ACTOR_ALLOWLIST"); v != "" {
+		cfg.DeploymentActorAllowlist = make(map[string]bool)
+		for _, actor := range strings.Split(v, ",") {
+			if actor = strings.TrimSpace(actor); actor != "" {
+				cfg.DeploymentActorAllowlist[actor] = true
+			}
+		}
+	}
+	cfg.DeploymentActorLimit = defaultDeploymentActorLimit
+	if v := os.Getenv("DEPLOYMENT_ACTOR_LIMIT"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 0 {
+			return fmt.Errorf("invalid DEPLOYMENT_ACTOR_LIMIT %q", v)
+		}
+		cfg.DeploymentActorLimit = limit
+	}
+	if v := os.Getenv("ADJUST_FREQUENCY_FOR_NEW_REPOS"); v != "" {
+		adjust, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid ADJUST_FREQUENCY_FOR_NEW_REPOS %q: %w", v, err)
+		}
+		cfg.AdjustFrequencyForNewRepos = adjust
+	}
+	if v := os.Getenv("FREQUENCY_HALF_LIFE"); v != "" {
+		halfLife, err := time.ParseDuration(v)
+		if err != nil || halfLife <= 0 {
+			return fmt.Errorf("invalid FREQUENCY_HALF_LIFE %q", v)
+		}
+		cfg.FrequencyHalfLife = halfLife
+	}
+	if v := os.Getenv("DEPLOYMENT_EXEMPLARS"); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid DEPLOYMENT_EXEMPLARS %q: %w", v, err)
+		}
+		cfg.DeploymentExemplars = enabled
+	}
+	if v := os.Getenv("FREQUENCY_COMPLETE_DAYS"); v != "" {
+		complete, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid FREQUENCY_COMPLETE_DAYS %q: %w", v, err)
+		}
+		cfg.FrequencyCompleteDays = complete
+	}
+	if v := os.Getenv("CONCLUSION_CLASSES"); v != "" {
+		classes, err := parseConclusionClasses(v)
+		if err != nil {
+			return fmt.Errorf("invalid CONCLUSION_CLASSES: %w", err)
+		}
+		cfg.ConclusionClasses = classes
+	}
+	if v := os.Getenv("SUCCESS_CONCLUSIONS"); v != "" {
+		classes, err := withSuccessConclusions(cfg.ConclusionClasses, v)
+		if err != nil {
+			return fmt.Errorf("invalid SUCCESS_CONCLUSIONS: %w", err)
+		}
+		cfg.ConclusionClasses = classes
+	}
+	if v := os.Getenv("CFR_AS_PERCENT"); v != "" {
+		percent, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid CFR_AS_PERCENT %q: %w", v, err)
+		}
+		cfg.CFRAsPercent = percent
+	}
+	if v := os.Getenv("EXCLUDE_WORKFLOWS"); v != "" {
+		cfg.ExcludeWorkflows = make(map[string]bool)
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				cfg.ExcludeWorkflows[name] = true
+			}
+		}
+	}
+	if v := os.Getenv("LEAD_TIME_DISTRIBUTION"); v != "" {
+		cfg.LeadTimeDistributions = make(map[string]bool)
+		for _, kind := range strings.Split(v, ",") {
+			switch kind = strings.TrimSpace(kind); kind {
+			case "":
+			case leadTimeDistributionHistogram, leadTimeDistributionSummary:
+				cfg.LeadTimeDistributions[kind] = true
+			default:
+				return fmt.Errorf("invalid LEAD_TIME_DISTRIBUTION %q: must be %q, %q or both", v, leadTimeDistributionHistogram, leadTimeDistributionSummary)
+			}
+		}
+	}
+	if v := os.Getenv("LEAD_TIME_MODE"); v != "" {
+		switch v {
+		case leadTimeModeRunDuration, leadTimeModeHeadCommit, leadTimeModeOldestCommit, leadTimeModeDeployInterval:
+			cfg.LeadTimeMode = v
+		default:
+			return fmt.Errorf("invalid LEAD_TIME_MODE %q: must be one of %q, %q, %q or %q", v, leadTimeModeRunDuration, leadTimeModeHeadCommit, leadTimeModeOldestCommit, leadTimeModeDeployInterval)
+		}
+	}
+	for _, pattern := range strings.Split(os.Getenv("PRODUCTION_PATHS"), ",") {
+		if pattern = strings.TrimSpace(pattern); pattern == "" {
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid PRODUCTION_PATHS pattern %q: %w", pattern, err)
+		}
+		cfg.ProductionPaths = append(cfg.ProductionPaths, pattern)
+	}
+	for _, prefix := range strings.Split(os.Getenv("HOTFIX_COMMIT_PREFIXES"), ",") {
+		if prefix = strings.TrimSpace(prefix); prefix != "" {
+			cfg.HotfixCommitPrefixes = append(cfg.HotfixCommitPrefixes, prefix)
+		}
+	}
+	if v := os.Getenv("HOTFIX_BRANCH_PATTERN"); v != "" {
+		if _, err := path.Match(v, ""); err != nil {
+			return fmt.Errorf("invalid HOTFIX_BRANCH_PATTERN %q: %w", v, err)
+		}
+		cfg.HotfixBranchPattern = v
+	}
+	if v := os.Getenv("HOTFIX_LABELS"); v != "" {
+		cfg.HotfixLabels = make(map[string]bool)
+		for _, label := range strings.Split(v, ",") {
+			if label = strings.TrimSpace(label); label != "" {
+				cfg.HotfixLabels[label] = true
+			}
+		}
+	}
+	if v := os.Getenv("PULL_REQUEST_METRICS"); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid PULL_REQUEST_METRICS %q: %w", v, err)
+		}
+		cfg.PullRequestMetrics = enabled
+	}
+	if v := os.Getenv("RESTORE_TIME_SOURCE"); v != "" {
+		if !validRestoreTimeSource(v) {
+			return fmt.Errorf("invalid RESTORE_TIME_SOURCE %q: must be one of %q, %q, %q, %q or %q", v, restoreTimeSourceIssues, restoreTimeSourceDeployments, restoreTimeSourceDeployRecovery, restoreTimeSourcePagerDuty, restoreTimeSourceDisabled)
+		}
+		cfg.RestoreTimeSource = v
+	}
+	if v := os.Getenv("MONOREPO_SERVICES"); v != "" {
+		services, err := parseMonorepoServices(v)
+		if err != nil {
+			return fmt.Errorf("invalid MONOREPO_SERVICES: %w", err)
+		}
+		cfg.MonorepoServices = services
+	}
+	if v := os.Getenv("REPO_RESTORE_TIME_SOURCES"); v != "" {
+		sources, err := parseKeyValueList(v)
+		if err != nil {
+			return fmt.Errorf("invalid REPO_RESTORE_TIME_SOURCES: %w", err)
+		}
+		cfg.RepoRestoreTimeSources = make(map[string]string, len(sources))
+		for repo, source := range sources {
+			if _, _, err := parseRepoFullName(repo); err != nil {
+				return fmt.Errorf("invalid REPO_RESTORE_TIME_SOURCES: %w", err)
+			}
+			if !validRestoreTimeSource(source) {
+				return fmt.Errorf("invalid REPO_RESTORE_TIME_SOURCES source %q for %s", source, repo)
+			}
+			cfg.RepoRestoreTimeSources[strings.ToLower(repo)] = source
+		}
+	}
+	if v := os.Getenv("PAGERDUTY_URL"); v != "" {
+		cfg.PagerDutyURL = strings.TrimSuffix(v, "/")
+	}
+	if v := os.Getenv("PAGERDUTY_SERVICES"); v != "" {
+		services, err := parseKeyValueList(v)
+		if err != nil {
+			return fmt.Errorf("invalid PAGERDUTY_SERVICES: %w", err)
+		}
+		cfg.PagerDutyServices = make(map[string]string, len(services))
+		for repo, service := range services {
+			if _, _, err := parseRepoFullName(repo); err != nil {
+				return fmt.Errorf("invalid PAGERDUTY_SERVICES: %w", err)
+			}
+			cfg.PagerDutyServices[strings.ToLower(repo)] = service
+		}
+	}
+	if v := os.Getenv("RESTORE_TIME_ENVIRONMENT"); v != "" {
+		cfg.RestoreTimeEnvironment = v
+	}
+	cfg.ResolutionLabel = os.Getenv("RESOLUTION_LABEL")
+	if v := os.Getenv("INCLUDE_OPEN_INCIDENTS"); v != "" {
+		include, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid INCLUDE_OPEN_INCIDENTS %q: %w", v, err)
+		}
+		cfg.IncludeOpenIncidents = include
+	}
+	if v := os.Getenv("MTTR_BUSINESS_HOURS"); v != "" {
+		hours, err := parseBusinessHours(v, os.Getenv("MTTR_TIMEZONE"), os.Getenv("MTTR_HOLIDAYS"))
+		if err != nil {
+			return fmt.Errorf("invalid MTTR_BUSINESS_HOURS: %w", err)
+		}
+		cfg.BusinessHours = hours
+	}
+	if v := os.Getenv("INCIDENT_SEVERITY_WEIGHTS"); v != "" {
+		weights, err := parseSeverityWeights(v)
+		if err != nil {
+			return fmt.Errorf("invalid INCIDENT_SEVERITY_WEIGHTS: %w", err)
+		}
+		cfg.SeverityWeights = weights
+	}
+	if v := os.Getenv("COMPOSITE_SCORE_WEIGHTS"); v != "" {
+		weights, err := parseCompositeScoreWeights(v)
+		if err != nil {
+			return fmt.Errorf("invalid COMPOSITE_SCORE_WEIGHTS: %w", err)
+		}
+		cfg.CompositeScoreWeights = weights
+	}
+	return nil
+}
+
+// getenvOrFile returns the value of the environment variable name or, if
+// name_FILE is set, the contents of that file with surrounding whitespace
+// trimmed. The file takes precedence, so that secrets mounted from Kubernetes
+// or a Vault agent need not be exposed in the environment.
+func getenvOrFile(name string) (string, error) {
+	file := os.Getenv(name + "_FILE")
+	if file == "" {
+		return os.Getenv(name), nil
+	}
+	contents, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("reading %s_FILE: %w", name, err)
+	}
+	return strings.TrimSpace(string(contents)), nil
+}
+
+// isDeploymentTrigger reports whether runs triggered by event count as
+// deployments.
+func isDeploymentTrigger(event string) bool {
+	return cfg.DeploymentTriggerEvents == nil || cfg.DeploymentTriggerEvents[event]
+}
+
+// enabledMetricNames maps the names accepted in ENABLED_METRICS to the
+// headline metrics.
+var enabledMetricNames = map[string]string{
+	"frequency": metricDeploymentFrequency,
+	"lead_time": metricLeadTimeForChanges,
+	"mttr":      metricTimeToRestoreService,
+	"cfr":       metricChangeFailureRate,
+}
+
+func parseEnabledMetrics(list string) (map[string]bool, error) {
+	enabled := make(map[string]bool)
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		metric, ok := enabledMetricNames[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown metric %q: must be frequency, lead_time, mttr or cfr", name)
+		}
+		enabled[metric] = true
+	}
+	return enabled, nil
+}
+
+// parseWindowDays reads a window length in days from the environment variable
+// name, returning fallback if it is not set.
+func parseWindowDays(name string, fallback int) (int, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return fallback, nil
+	}
+	days, err := strconv.Atoi(v)
+	if err != nil || days <= 0 {
+		return 0, fmt.Errorf("invalid %s %q", name, v)
+	}
+	return days, nil
+}
+
+func validRestoreTimeSource(source string) bool {
+	switch source {
+	case restoreTimeSourceIssues, restoreTimeSourceDeployments, restoreTimeSourceDeployRecovery, restoreTimeSourcePagerDuty, restoreTimeSourceDisabled:
+		return true
+	}
+	return false
+}
+
+// restoreTimeSource returns how Time to Restore Service is measured for
+// repoFullName: its REPO_RESTORE_TIME_SOURCES entry, or RESTORE_TIME_SOURCE.
+func restoreTimeSource(repoFullName string) string {
+	if source, ok := cfg.RepoRestoreTimeSources[strings.ToLower(repoFullName)]; ok {
+		return source
+	}
+	return cfg.RestoreTimeSource
+}
+
+// parseKeyValueList parses a comma-separated list of key=value pairs.
+func parseKeyValueList(list string) (map[string]string, error) {
+	values := make(map[string]string)
+	for _, pair := range strings.Split(list, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if !ok || key == "" || value == "" {
+			return nil, fmt.Errorf("expected key=value, got %q", pair)
+		}
+		values[key] = value
+	}
+	return values, nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// redacted stands in for the value of a secret that is set.
+const redacted = "***"
+
+type configResponse struct {
+	Config config `json:"config"`
+	// Secrets maps each secret setting to "***" if it is set and to "" if
+	// it is not. Secret values are never returned.
+	Secrets map[string]string `json:"secrets"`
+	// Tracked lists the repo/branches metrics have been computed for.
+	Tracked []seriesKey `json:"tracked"`
+}
+
+// newConfigHandler serves GET /config, returning the effective configuration
+// resolved at startup. setSecrets reports which secret settings are set.
+func newConfigHandler(setSecrets map[string]bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		response := configResponse{
+			Config:  cfg,
+			Secrets: make(map[string]string, len(setSecrets)+1),
+			Tracked: seenKeys.keys(),
+		}
+		for name, set := range setSecrets {
+			response.Secrets[name] = ""
+			if set {
+				response.Secrets[name] = redacted
+			}
+		}
+		// Sink URLs often carry an API key.
+		if response.Config.MetricsSinkURL != "" {
+			response.Config.MetricsSinkURL = redacted
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			log.Printf("Error encoding config to JSON: %v", err)
+		}
+	}
+}
+
+// MarshalJSON presents the window as it is configured, e.g. "09:00-17:00".
+func (h *businessHours) MarshalJSON() ([]byte, error) {
+	holidays := make([]string, 0, len(h.Holidays))
+	for holiday := range h.Holidays {
+		holidays = append(holidays, holiday)
+	}
+	sort.Strings(holidays)
+	return json.Marshal(struct {
+		Window   string
+		Timezone string
+		Holidays []string
+	}{
+		Window:   fmt.Sprintf("%s-%s", clockTime(h.Start), clockTime(h.End)),
+		Timezone: h.Location.String(),
+		Holidays: holidays,
+	})
+}
+
+// clockTime formats an offset from midnight as HH:MM.
+func clockTime(offset time.Duration) string {
+	minutes := int(offset.Minutes())
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"container/list"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	defaultDeliveryCacheSize = 1000
+	defaultDeliveryCacheTTL  = time.Hour
+)
+
+// deliveries holds the webhook deliveries processed recently. It is nil when
+// WEBHOOK_DELIVERY_CACHE_SIZE is 0.
+var deliveries *deliveryCache
+
+// deliveryCache is a bounded LRU of webhook delivery IDs, used to acknowledge
+// replayed or re-delivered payloads without processing them again.
+type deliveryCache struct {
+	size int
+	ttl  time.Duration
+
+	mu sync.Mutex
+	// order holds the IDs from most to least recently seen.
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type deliveryEntry struct {
+	id     string
+	seenAt time.Time
+}
+
+// newDeliveryCache returns nil, which remembers nothing, when size is 0.
+func newDeliveryCache(size int, ttl time.Duration) *deliveryCache {
+	if size == 0 {
+		return nil
+	}
+	return &deliveryCache{
+		size:    size,
+		ttl:     ttl,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// seen records the delivery id and reports whether it had already been
+// recorded within the TTL. Empty IDs are never considered seen.
+func (c *deliveryCache) seen(id string) bool {
+	if c == nil || id == "" {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if element, ok := c.entries[id]; ok {
+		entry := element.Value.(*deliveryEntry)
+		if now.Sub(entry.seenAt) < c.ttl {
+			c.order.MoveToFront(element)
+			return true
+		}
+		entry.seenAt = now
+		c.order.MoveToFront(element)
+		return false
+	}
+
+	c.entries[id] = c.order.PushFront(&deliveryEntry{id: id, seenAt: now})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*deliveryEntry).id)
+	}
+	return false
+}
+
+// forget removes the delivery id, so that a redelivery of it is processed.
+func (c *deliveryCache) forget(id string) {
+	if c == nil || id == "" {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[id]; ok {
+		c.order.Remove(element)
+		delete(c.entries, id)
+	}
+}
+
+// deliveryResponseWriter captures the status of the response to a delivery.
+type deliveryResponseWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *deliveryResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *deliveryResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// processDelivery reports whether the delivery id is new and should be
+// processed. Processing answers through the returned ResponseWriter and then
+// calls done: unless the answer was a 2xx, e.g. because the recompute
+// failed or the queue was full, the delivery is forgotten again so that its
+// redelivery, which carries the same id, is processed.
+func (c *deliveryCache) processDelivery(w http.ResponseWriter, id string) (http.ResponseWriter, func(), bool) {
+	if c.seen(id) {
+		return w, func() {}, false
+	}
+	response := &deliveryResponseWriter{ResponseWriter: w}
+	return response, func() {
+		if response.status >= http.StatusMultipleChoices {
+			c.forget(id)
+		}
+	}, true
+}
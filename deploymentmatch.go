@@ -0,0 +1,46 @@
+package main
+
+import "regexp"
+
+// deploymentNameMatch is what DEPLOYMENT_MATCH_REGEX extracted from a
+// workflow or check run name.
+type deploymentNameMatch struct {
+	Environment string
+	Version     string
+}
+
+// matchDeploymentName reports whether a workflow or check run named name is
+// a deployment. Without DEPLOYMENT_MATCH_REGEX every name matches; with it,
+// the "environment" and "version" named groups are returned when present.
+func matchDeploymentName(name string) (deploymentNameMatch, bool) {
+	if cfg.DeploymentMatchRegex == nil {
+		return deploymentNameMatch{}, true
+	}
+	submatches := cfg.DeploymentMatchRegex.FindStringSubmatch(name)
+	if submatches == nil {
+		return deploymentNameMatch{}, false
+	}
+	return deploymentNameMatch{
+		Environment: namedSubmatch(cfg.DeploymentMatchRegex, submatches, "environment"),
+		Version:     namedSubmatch(cfg.DeploymentMatchRegex, submatches, "version"),
+	}, true
+}
+
+// namedSubmatch returns the text matched by the named group, or "" if re has
+// no such group or it did not participate in the match.
+func namedSubmatch(re *regexp.Regexp, submatches []string, name string) string {
+	if i := re.SubexpIndex(name); i >= 0 {
+		return submatches[i]
+	}
+	return ""
+}
+
+// isDeploymentCheckRun reports whether a check run named name marks a
+// deployment when DEPLOYMENT_SOURCE is "checks".
+func isDeploymentCheckRun(name string) bool {
+	if cfg.DeploymentCheckName != "" && name != cfg.DeploymentCheckName {
+		return false
+	}
+	_, ok := matchDeploymentName(name)
+	return ok
+}
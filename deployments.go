@@ -0,0 +1,294 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const defaultEnvironment = "default"
+
+const (
+	windowBasisCompleted = "completed"
+	windowBasisCreated   = "created"
+)
+
+// maxRunDuration is how long before a window a run can have been created and
+// still complete inside it with DEPLOYMENT_WINDOW_BASIS=completed.
+const maxRunDuration = 24 * time.Hour
+
+// deploymentTime returns when a run or deployment created at createdAt and
+// completed at completedAt happened, as configured by DEPLOYMENT_WINDOW_BASIS.
+// It decides which window the run falls in.
+func deploymentTime(createdAt time.Time, completedAt time.Time) time.Time {
+	if cfg.DeploymentWindowBasis == windowBasisCreated {
+		return createdAt
+	}
+	return completedAt
+}
+
+// hasRunTimes reports whether a run has both a creation and a completion
+// time, logging the run when it does not. The go-github getters return the
+// zero time for missing timestamps, which would otherwise place the run in
+// 1970 and drop it from every window without a trace.
+func hasRunTimes(repoFullName string, kind string, id int64, createdAt time.Time, completedAt time.Time) bool {
+	switch {
+	case createdAt.IsZero() && completedAt.IsZero():
+		log.Printf("Skipping %s %d of %s: it has no creation or completion time", kind, id, repoFullName)
+	case createdAt.IsZero():
+		log.Printf("Skipping %s %d of %s: it has no creation time", kind, id, repoFullName)
+	case completedAt.IsZero():
+		log.Printf("Skipping %s %d of %s: it has no completion time", kind, id, repoFullName)
+	default:
+		return true
+	}
+	return false
+}
+
+// earliestCreation returns the earliest creation time of the runs that can
+// fall in a window starting at since.
+func earliestCreation(since time.Time) time.Time {
+	if cfg.DeploymentWindowBasis == windowBasisCreated {
+		return since
+	}
+	return since.Add(-maxRunDuration)
+}
+
+// deploymentAttempt is a finished deployment, independent of the source it
+// was read from.
+type deploymentAttempt struct {
+	Environment string
+	// Workflow is the name of the workflow run or pipeline, if the attempt
+	// was read from one.
+	Workflow string
+	// Version is the deployed version, if known.
+	Version string
+	// HeadSHA is the commit that was deployed, if known.
+	HeadSHA string
+	// Actor is the login of the user who triggered the deployment, if known.
+	Actor       string
+	CreatedAt   time.Time
+	CompletedAt time.Time
+	Successful  bool
+}
+
+// DailyCount is the number of deployment attempts started on one UTC day.
+type DailyCount struct {
+	Date        string `json:"date"`
+	Deployments int    `json:"deployments"`
+}
+
+// dailyDeploymentCounts buckets attempts by the UTC day they happened on,
+// returning one entry for every day from since to now, oldest first. The first
+// and last days are only partially covered by the window.
+func dailyDeploymentCounts(attempts []deploymentAttempt, since time.Time, now time.Time) []DailyCount {
+	const layout = "2006-01-02"
+	counts := make(map[string]int)
+	for _, attempt := range attempts {
+		counts[deploymentTime(attempt.CreatedAt, attempt.CompletedAt).UTC().Format(layout)]++
+	}
+
+	var daily []DailyCount
+	day := since.UTC().Truncate(24 * time.Hour)
+	for !day.After(now.UTC()) {
+		date := day.Format(layout)
+		daily = append(daily, DailyCount{Date: date, Deployments: counts[date]})
+		day = day.AddDate(0, 0, 1)
+	}
+	return daily
+}
+
+// weightedDeploymentFrequency returns the deployments per day at times in
+// the windowDays before end, each weighted by 0.5^(age/halfLife). The weights
+// are normalized by their integral over the window, so that a steady rate
+// comes out the same as the flat average while recent changes in the rate
+// show sooner.
+func weightedDeploymentFrequency(times []time.Time, end time.Time, windowDays float64, halfLife time.Duration) float64 {
+	halfLifeDays := halfLife.Hours() / 24
+	var total float64
+	for _, t := range times {
+		total += math.Pow(0.5, end.Sub(t).Hours()/24/halfLifeDays)
+	}
+	// The integral of 0.5^(age/halfLife) over ages 0 to windowDays.
+	norm := halfLifeDays / math.Ln2 * (1 - math.Pow(0.5, windowDays/halfLifeDays))
+	return total / norm
+}
+
+// deploymentResult is a deployment together with its most recent status,
+// expressed with the GitHub Deployments API states.
+type deploymentResult struct {
+	Environment string
+	CreatedAt   time.Time
+	State       string
+	// FinishedAt is when the latest status was reported.
+	FinishedAt time.Time
+}
+
+// attemptsFromDeploymentResults converts deployments in a final state into
+// deployment attempts.
+func attemptsFromDeploymentResults(deployments []deploymentResult) []deploymentAttempt {
+	var attempts []deploymentAttempt
+	for _, deployment := range deployments {
+		var successful bool
+		switch deployment.State {
+		// A deployment becomes inactive once a newer one to the same
+		// environment has succeeded, so it was successful itself.
+		case "success", "inactive":
+			successful = true
+		case "failure", "error":
+			successful = false
+		default:
+			continue
+		}
+		environment := deployment.Environment
+		if environment == "" {
+			environment = defaultEnvironment
+		}
+		attempts = append(attempts, deploymentAttempt{
+			Environment: environment,
+			CreatedAt:   deployment.CreatedAt,
+			CompletedAt: deployment.FinishedAt,
+			Successful:  successful,
+		})
+	}
+	return attempts
+}
+
+// timeToRestoreFromDeployments measures, in hours, the average time
+// from a failed deployment to the next successful deployment to the same
+// environment. Each such recovery counts as an incident.
+func (c *Calculator) timeToRestoreFromDeployments(repoFullName string, branch string) (*restoreStats, error) {
+	log.Printf("Calculating Time to Restore Service from %s deployments for %s on branch %s", cfg.RestoreTimeEnvironment, repoFullName, branch)
+
+	deployments, listErr := c.provider.ListEnvironmentDeployments(repoFullName, branch, cfg.RestoreTimeEnvironment, c.now().AddDate(0, 0, -cfg.RestoreTimeWindowDays))
+	if listErr != nil {
+		listErr = fmt.Errorf("fetching deployments: %w", listErr)
+		if !isPartialList(listErr) {
+			return nil, listErr
+		}
+	}
+
+	totalRestoreTime := 0.0
+	recoveries := 0
+	var failedAt time.Time
+	for _, deployment := range deployments {
+		switch deployment.State {
+		case "failure", "error":
+			if failedAt.IsZero() {
+				failedAt = deployment.FinishedAt
+			}
+		// Like a success, an inactive deployment recovered the environment
+		// before a newer deployment superseded it.
+		case "success", "inactive":
+			if !failedAt.IsZero() {
+				totalRestoreTime += restoreDuration(failedAt, deployment.FinishedAt).Hours()
+				recoveries++
+				failedAt = time.Time{}
+			}
+		}
+	}
+
+	if recoveries == 0 {
+		return &restoreStats{}, listErr
+	}
+	avgRestoreTime := totalRestoreTime / float64(recoveries)
+	log.Printf("Calculated Time to Restore Service: %f hours over %d recoveries", avgRestoreTime, recoveries)
+	return &restoreStats{Hours: avgRestoreTime, Incidents: recoveries}, listErr
+}
+
+// timeToRestoreFromDeployRecovery measures, in hours, the average
+// time from a failed deployment attempt, read from DEPLOYMENT_SOURCE, to the
+// next successful one in the same environment. Each such recovery counts as
+// an incident.
+func (c *Calculator) timeToRestoreFromDeployRecovery(repoFullName string, branch string) (*restoreStats, error) {
+	log.Printf("Calculating Time to Restore Service from deploy recoveries for %s on branch %s", repoFullName, branch)
+
+	attempts, listErr := c.provider.ListDeploymentAttempts(repoFullName, branch, c.now().AddDate(0, 0, -cfg.RestoreTimeWindowDays))
+	if listErr != nil && !isPartialList(listErr) {
+		return nil, listErr
+	}
+	attempts, err := filterProductionAttempts(c.provider, repoFullName, attempts)
+	if err != nil {
+		return nil, err
+	}
+
+	minutes, recoveries := deployRecoveryTime(attempts)
+	if recoveries == 0 {
+		return &restoreStats{}, listErr
+	}
+	log.Printf("Calculated Time to Restore Service: %f hours over %d recoveries", minutes/60, recoveries)
+	return &restoreStats{Hours: minutes / 60, Incidents: recoveries}, listErr
+}
+
+// deployRecoveryTime returns the average minutes from each failed attempt to
+// the next successful one in the same environment, and how many failed
+// attempts were followed by a success. Failures not followed by one yet are
+// left out.
+func deployRecoveryTime(attempts []deploymentAttempt) (float64, int) {
+	sorted := slices.Clone(attempts)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].CompletedAt.Before(sorted[j].CompletedAt)
+	})
+
+	failedSince := make(map[string][]time.Time)
+	var total time.Duration
+	var recoveries int
+	for _, attempt := range sorted {
+		if !attempt.Successful {
+			failedSince[attempt.Environment] = append(failedSince[attempt.Environment], attempt.CompletedAt)
+			continue
+		}
+		for _, failedAt := range failedSince[attempt.Environment] {
+			total += attempt.CompletedAt.Sub(failedAt)
+			recoveries++
+		}
+		delete(failedSince, attempt.Environment)
+	}
+	if recoveries == 0 {
+		return 0, 0
+	}
+	return total.Minutes() / float64(recoveries), recoveries
+}
+
+// deploymentObservations tracks the attempts counted in deploymentsTotal.
+var deploymentObservations = newObservationSets()
+
+// observeDeployments counts the attempts, listed from the window starting at
+// since, not counted before in deploymentsTotal, attaching the deployed
+// commit as an exemplar so that a dashboard can link a change in the rate to
+// the deployments behind it. branch is the queried branch; the attempts of
+// the __all__ aggregate, queried with an empty branch, are already counted in
+// the series of their branch.
+func observeDeployments(repoFullName string, branch string, attempts []deploymentAttempt, since time.Time) {
+	if deploymentsTotal == nil || branch == "" {
+		return
+	}
+
+	key := seriesKey{Repo: repoFullName, Branch: branch}
+	for _, attempt := range attempts {
+		if !deploymentObservations.observe(key, attemptIdentity(attempt), attempt.CompletedAt, since) {
+			continue
+		}
+		result := "success"
+		if !attempt.Successful {
+			result = "failure"
+		}
+		counter := deploymentsTotal.WithLabelValues(branch, repoFullName, attempt.Environment, result)
+		if attempt.HeadSHA == "" {
+			counter.Inc()
+			continue
+		}
+		counter.(prometheus.ExemplarAdder).AddWithExemplar(1, prometheus.Labels{"commit": attempt.HeadSHA})
+	}
+}
+
+// attemptIdentity tells apart the deployment attempts of a repo/branch,
+// including those of one run deploying to several environments at once.
+func attemptIdentity(attempt deploymentAttempt) string {
+	return fmt.Sprintf("%s|%s|%s|%d|%d", attempt.Environment, attempt.Workflow, attempt.HeadSHA, attempt.CreatedAt.UnixNano(), attempt.CompletedAt.UnixNano())
+}
@@ -0,0 +1,576 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.34.2
+// 	protoc        (unknown)
+// source: dorapb/dora.proto
+
+package dorapb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type GetDoraMetricsRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Repo is the repository as owner/name.
+	Repo   string `protobuf:"bytes,1,opt,name=repo,proto3" json:"repo,omitempty"`
+	Branch string `protobuf:"bytes,2,opt,name=branch,proto3" json:"branch,omitempty"`
+}
+
+func (x *GetDoraMetricsRequest) Reset() {
+	*x = GetDoraMetricsRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dorapb_dora_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetDoraMetricsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetDoraMetricsRequest) ProtoMessage() {}
+
+func (x *GetDoraMetricsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_dorapb_dora_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetDoraMetricsRequest.ProtoReflect.Descriptor instead.
+func (*GetDoraMetricsRequest) Descriptor() ([]byte, []int) {
+	return file_dorapb_dora_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *GetDoraMetricsRequest) GetRepo() string {
+	if x != nil {
+		return x.Repo
+	}
+	return ""
+}
+
+func (x *GetDoraMetricsRequest) GetBranch() string {
+	if x != nil {
+		return x.Branch
+	}
+	return ""
+}
+
+type StreamMetricsRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Repo and branch, if set, only stream the metrics of that repository or
+	// branch.
+	Repo   string `protobuf:"bytes,1,opt,name=repo,proto3" json:"repo,omitempty"`
+	Branch string `protobuf:"bytes,2,opt,name=branch,proto3" json:"branch,omitempty"`
+}
+
+func (x *StreamMetricsRequest) Reset() {
+	*x = StreamMetricsRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dorapb_dora_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *StreamMetricsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamMetricsRequest) ProtoMessage() {}
+
+func (x *StreamMetricsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_dorapb_dora_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamMetricsRequest.ProtoReflect.Descriptor instead.
+func (*StreamMetricsRequest) Descriptor() ([]byte, []int) {
+	return file_dorapb_dora_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *StreamMetricsRequest) GetRepo() string {
+	if x != nil {
+		return x.Repo
+	}
+	return ""
+}
+
+func (x *StreamMetricsRequest) GetBranch() string {
+	if x != nil {
+		return x.Branch
+	}
+	return ""
+}
+
+// DoraMetrics mirrors the JSON returned by the webhook and /recompute
+// endpoints. Units are those listed in units.
+type DoraMetrics struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Repo                       string                 `protobuf:"bytes,1,opt,name=repo,proto3" json:"repo,omitempty"`
+	Branch                     string                 `protobuf:"bytes,2,opt,name=branch,proto3" json:"branch,omitempty"`
+	DeploymentFrequency        float64                `protobuf:"fixed64,3,opt,name=deployment_frequency,json=deploymentFrequency,proto3" json:"deployment_frequency,omitempty"`
+	DeploymentFrequencyDays    float64                `protobuf:"fixed64,4,opt,name=deployment_frequency_days,json=deploymentFrequencyDays,proto3" json:"deployment_frequency_days,omitempty"`
+	LeadTimeForChanges         float64                `protobuf:"fixed64,5,opt,name=lead_time_for_changes,json=leadTimeForChanges,proto3" json:"lead_time_for_changes,omitempty"`
+	LeadTimeForNormalChanges   float64                `protobuf:"fixed64,6,opt,name=lead_time_for_normal_changes,json=leadTimeForNormalChanges,proto3" json:"lead_time_for_normal_changes,omitempty"`
+	LeadTimeForHotfixes        float64                `protobuf:"fixed64,7,opt,name=lead_time_for_hotfixes,json=leadTimeForHotfixes,proto3" json:"lead_time_for_hotfixes,omitempty"`
+	LeadTimeSampleCount        int64                  `protobuf:"varint,8,opt,name=lead_time_sample_count,json=leadTimeSampleCount,proto3" json:"lead_time_sample_count,omitempty"`
+	HotfixCount                int64                  `protobuf:"varint,9,opt,name=hotfix_count,json=hotfixCount,proto3" json:"hotfix_count,omitempty"`
+	TimeToRestoreService       float64                `protobuf:"fixed64,10,opt,name=time_to_restore_service,json=timeToRestoreService,proto3" json:"time_to_restore_service,omitempty"`
+	IncidentCount              int64                  `protobuf:"varint,11,opt,name=incident_count,json=incidentCount,proto3" json:"incident_count,omitempty"`
+	ChangeFailureRate          float64                `protobuf:"fixed64,12,opt,name=change_failure_rate,json=changeFailureRate,proto3" json:"change_failure_rate,omitempty"`
+	ChangeFailures             int64                  `protobuf:"varint,13,opt,name=change_failures,json=changeFailures,proto3" json:"change_failures,omitempty"`
+	DeploymentAttempts         int64                  `protobuf:"varint,14,opt,name=deployment_attempts,json=deploymentAttempts,proto3" json:"deployment_attempts,omitempty"`
+	SuccessfulDeployments      int64                  `protobuf:"varint,15,opt,name=successful_deployments,json=successfulDeployments,proto3" json:"successful_deployments,omitempty"`
+	FailedDeployments          int64                  `protobuf:"varint,16,opt,name=failed_deployments,json=failedDeployments,proto3" json:"failed_deployments,omitempty"`
+	SecondsSinceLastDeployment float64                `protobuf:"fixed64,17,opt,name=seconds_since_last_deployment,json=secondsSinceLastDeployment,proto3" json:"seconds_since_last_deployment,omitempty"`
+	CompositeScore             float64                `protobuf:"fixed64,18,opt,name=composite_score,json=compositeScore,proto3" json:"composite_score,omitempty"`
+	Units                      map[string]string      `protobuf:"bytes,19,rep,name=units,proto3" json:"units,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	InsufficientSamples        []string               `protobuf:"bytes,20,rep,name=insufficient_samples,json=insufficientSamples,proto3" json:"insufficient_samples,omitempty"`
+	Errors                     map[string]string      `protobuf:"bytes,21,rep,name=errors,proto3" json:"errors,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	ComputedAt                 *timestamppb.Timestamp `protobuf:"bytes,22,opt,name=computed_at,json=computedAt,proto3" json:"computed_at,omitempty"`
+	// Partial maps a sub-metric name to the error that cut short a listing it
+	// was calculated from. The value only covers what was fetched.
+	Partial map[string]string `protobuf:"bytes,23,rep,name=partial,proto3" json:"partial,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+}
+
+func (x *DoraMetrics) Reset() {
+	*x = DoraMetrics{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dorapb_dora_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DoraMetrics) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DoraMetrics) ProtoMessage() {}
+
+func (x *DoraMetrics) ProtoReflect() protoreflect.Message {
+	mi := &file_dorapb_dora_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DoraMetrics.ProtoReflect.Descriptor instead.
+func (*DoraMetrics) Descriptor() ([]byte, []int) {
+	return file_dorapb_dora_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *DoraMetrics) GetRepo() string {
+	if x != nil {
+		return x.Repo
+	}
+	return ""
+}
+
+func (x *DoraMetrics) GetBranch() string {
+	if x != nil {
+		return x.Branch
+	}
+	return ""
+}
+
+func (x *DoraMetrics) GetDeploymentFrequency() float64 {
+	if x != nil {
+		return x.DeploymentFrequency
+	}
+	return 0
+}
+
+func (x *DoraMetrics) GetDeploymentFrequencyDays() float64 {
+	if x != nil {
+		return x.DeploymentFrequencyDays
+	}
+	return 0
+}
+
+func (x *DoraMetrics) GetLeadTimeForChanges() float64 {
+	if x != nil {
+		return x.LeadTimeForChanges
+	}
+	return 0
+}
+
+func (x *DoraMetrics) GetLeadTimeForNormalChanges() float64 {
+	if x != nil {
+		return x.LeadTimeForNormalChanges
+	}
+	return 0
+}
+
+func (x *DoraMetrics) GetLeadTimeForHotfixes() float64 {
+	if x != nil {
+		return x.LeadTimeForHotfixes
+	}
+	return 0
+}
+
+func (x *DoraMetrics) GetLeadTimeSampleCount() int64 {
+	if x != nil {
+		return x.LeadTimeSampleCount
+	}
+	return 0
+}
+
+func (x *DoraMetrics) GetHotfixCount() int64 {
+	if x != nil {
+		return x.HotfixCount
+	}
+	return 0
+}
+
+func (x *DoraMetrics) GetTimeToRestoreService() float64 {
+	if x != nil {
+		return x.TimeToRestoreService
+	}
+	return 0
+}
+
+func (x *DoraMetrics) GetIncidentCount() int64 {
+	if x != nil {
+		return x.IncidentCount
+	}
+	return 0
+}
+
+func (x *DoraMetrics) GetChangeFailureRate() float64 {
+	if x != nil {
+		return x.ChangeFailureRate
+	}
+	return 0
+}
+
+func (x *DoraMetrics) GetChangeFailures() int64 {
+	if x != nil {
+		return x.ChangeFailures
+	}
+	return 0
+}
+
+func (x *DoraMetrics) GetDeploymentAttempts() int64 {
+	if x != nil {
+		return x.DeploymentAttempts
+	}
+	return 0
+}
+
+func (x *DoraMetrics) GetSuccessfulDeployments() int64 {
+	if x != nil {
+		return x.SuccessfulDeployments
+	}
+	return 0
+}
+
+func (x *DoraMetrics) GetFailedDeployments() int64 {
+	if x != nil {
+		return x.FailedDeployments
+	}
+	return 0
+}
+
+func (x *DoraMetrics) GetSecondsSinceLastDeployment() float64 {
+	if x != nil {
+		return x.SecondsSinceLastDeployment
+	}
+	return 0
+}
+
+func (x *DoraMetrics) GetCompositeScore() float64 {
+	if x != nil {
+		return x.CompositeScore
+	}
+	return 0
+}
+
+func (x *DoraMetrics) GetUnits() map[string]string {
+	if x != nil {
+		return x.Units
+	}
+	return nil
+}
+
+func (x *DoraMetrics) GetInsufficientSamples() []string {
+	if x != nil {
+		return x.InsufficientSamples
+	}
+	return nil
+}
+
+func (x *DoraMetrics) GetErrors() map[string]string {
+	if x != nil {
+		return x.Errors
+	}
+	return nil
+}
+
+func (x *DoraMetrics) GetComputedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ComputedAt
+	}
+	return nil
+}
+
+func (x *DoraMetrics) GetPartial() map[string]string {
+	if x != nil {
+		return x.Partial
+	}
+	return nil
+}
+
+var File_dorapb_dora_proto protoreflect.FileDescriptor
+
+var file_dorapb_dora_proto_rawDesc = []byte{
+	0x0a, 0x11, 0x64, 0x6f, 0x72, 0x61, 0x70, 0x62, 0x2f, 0x64, 0x6f, 0x72, 0x61, 0x2e, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x12, 0x07, 0x64, 0x6f, 0x72, 0x61, 0x2e, 0x76, 0x31, 0x1a, 0x1f, 0x67, 0x6f,
+	0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2f, 0x74, 0x69,
+	0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x43, 0x0a,
+	0x15, 0x47, 0x65, 0x74, 0x44, 0x6f, 0x72, 0x61, 0x4d, 0x65, 0x74, 0x72, 0x69, 0x63, 0x73, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x72, 0x65, 0x70, 0x6f, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x72, 0x65, 0x70, 0x6f, 0x12, 0x16, 0x0a, 0x06, 0x62, 0x72,
+	0x61, 0x6e, 0x63, 0x68, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x62, 0x72, 0x61, 0x6e,
+	0x63, 0x68, 0x22, 0x42, 0x0a, 0x14, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x4d, 0x65, 0x74, 0x72,
+	0x69, 0x63, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x72, 0x65,
+	0x70, 0x6f, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x72, 0x65, 0x70, 0x6f, 0x12, 0x16,
+	0x0a, 0x06, 0x62, 0x72, 0x61, 0x6e, 0x63, 0x68, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06,
+	0x62, 0x72, 0x61, 0x6e, 0x63, 0x68, 0x22, 0xb1, 0x0a, 0x0a, 0x0b, 0x44, 0x6f, 0x72, 0x61, 0x4d,
+	0x65, 0x74, 0x72, 0x69, 0x63, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x72, 0x65, 0x70, 0x6f, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x72, 0x65, 0x70, 0x6f, 0x12, 0x16, 0x0a, 0x06, 0x62, 0x72,
+	0x61, 0x6e, 0x63, 0x68, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x62, 0x72, 0x61, 0x6e,
+	0x63, 0x68, 0x12, 0x31, 0x0a, 0x14, 0x64, 0x65, 0x70, 0x6c, 0x6f, 0x79, 0x6d, 0x65, 0x6e, 0x74,
+	0x5f, 0x66, 0x72, 0x65, 0x71, 0x75, 0x65, 0x6e, 0x63, 0x79, 0x18, 0x03, 0x20, 0x01, 0x28, 0x01,
+	0x52, 0x13, 0x64, 0x65, 0x70, 0x6c, 0x6f, 0x79, 0x6d, 0x65, 0x6e, 0x74, 0x46, 0x72, 0x65, 0x71,
+	0x75, 0x65, 0x6e, 0x63, 0x79, 0x12, 0x3a, 0x0a, 0x19, 0x64, 0x65, 0x70, 0x6c, 0x6f, 0x79, 0x6d,
+	0x65, 0x6e, 0x74, 0x5f, 0x66, 0x72, 0x65, 0x71, 0x75, 0x65, 0x6e, 0x63, 0x79, 0x5f, 0x64, 0x61,
+	0x79, 0x73, 0x18, 0x04, 0x20, 0x01, 0x28, 0x01, 0x52, 0x17, 0x64, 0x65, 0x70, 0x6c, 0x6f, 0x79,
+	0x6d, 0x65, 0x6e, 0x74, 0x46, 0x72, 0x65, 0x71, 0x75, 0x65, 0x6e, 0x63, 0x79, 0x44, 0x61, 0x79,
+	0x73, 0x12, 0x31, 0x0a, 0x15, 0x6c, 0x65, 0x61, 0x64, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x5f, 0x66,
+	0x6f, 0x72, 0x5f, 0x63, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x73, 0x18, 0x05, 0x20, 0x01, 0x28, 0x01,
+	0x52, 0x12, 0x6c, 0x65, 0x61, 0x64, 0x54, 0x69, 0x6d, 0x65, 0x46, 0x6f, 0x72, 0x43, 0x68, 0x61,
+	0x6e, 0x67, 0x65, 0x73, 0x12, 0x3e, 0x0a, 0x1c, 0x6c, 0x65, 0x61, 0x64, 0x5f, 0x74, 0x69, 0x6d,
+	0x65, 0x5f, 0x66, 0x6f, 0x72, 0x5f, 0x6e, 0x6f, 0x72, 0x6d, 0x61, 0x6c, 0x5f, 0x63, 0x68, 0x61,
+	0x6e, 0x67, 0x65, 0x73, 0x18, 0x06, 0x20, 0x01, 0x28, 0x01, 0x52, 0x18, 0x6c, 0x65, 0x61, 0x64,
+	0x54, 0x69, 0x6d, 0x65, 0x46, 0x6f, 0x72, 0x4e, 0x6f, 0x72, 0x6d, 0x61, 0x6c, 0x43, 0x68, 0x61,
+	0x6e, 0x67, 0x65, 0x73, 0x12, 0x33, 0x0a, 0x16, 0x6c, 0x65, 0x61, 0x64, 0x5f, 0x74, 0x69, 0x6d,
+	0x65, 0x5f, 0x66, 0x6f, 0x72, 0x5f, 0x68, 0x6f, 0x74, 0x66, 0x69, 0x78, 0x65, 0x73, 0x18, 0x07,
+	0x20, 0x01, 0x28, 0x01, 0x52, 0x13, 0x6c, 0x65, 0x61, 0x64, 0x54, 0x69, 0x6d, 0x65, 0x46, 0x6f,
+	0x72, 0x48, 0x6f, 0x74, 0x66, 0x69, 0x78, 0x65, 0x73, 0x12, 0x33, 0x0a, 0x16, 0x6c, 0x65, 0x61,
+	0x64, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x5f, 0x73, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x5f, 0x63, 0x6f,
+	0x75, 0x6e, 0x74, 0x18, 0x08, 0x20, 0x01, 0x28, 0x03, 0x52, 0x13, 0x6c, 0x65, 0x61, 0x64, 0x54,
+	0x69, 0x6d, 0x65, 0x53, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x12, 0x21,
+	0x0a, 0x0c, 0x68, 0x6f, 0x74, 0x66, 0x69, 0x78, 0x5f, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x18, 0x09,
+	0x20, 0x01, 0x28, 0x03, 0x52, 0x0b, 0x68, 0x6f, 0x74, 0x66, 0x69, 0x78, 0x43, 0x6f, 0x75, 0x6e,
+	0x74, 0x12, 0x35, 0x0a, 0x17, 0x74, 0x69, 0x6d, 0x65, 0x5f, 0x74, 0x6f, 0x5f, 0x72, 0x65, 0x73,
+	0x74, 0x6f, 0x72, 0x65, 0x5f, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x18, 0x0a, 0x20, 0x01,
+	0x28, 0x01, 0x52, 0x14, 0x74, 0x69, 0x6d, 0x65, 0x54, 0x6f, 0x52, 0x65, 0x73, 0x74, 0x6f, 0x72,
+	0x65, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x25, 0x0a, 0x0e, 0x69, 0x6e, 0x63, 0x69,
+	0x64, 0x65, 0x6e, 0x74, 0x5f, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x03,
+	0x52, 0x0d, 0x69, 0x6e, 0x63, 0x69, 0x64, 0x65, 0x6e, 0x74, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x12,
+	0x2e, 0x0a, 0x13, 0x63, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x5f, 0x66, 0x61, 0x69, 0x6c, 0x75, 0x72,
+	0x65, 0x5f, 0x72, 0x61, 0x74, 0x65, 0x18, 0x0c, 0x20, 0x01, 0x28, 0x01, 0x52, 0x11, 0x63, 0x68,
+	0x61, 0x6e, 0x67, 0x65, 0x46, 0x61, 0x69, 0x6c, 0x75, 0x72, 0x65, 0x52, 0x61, 0x74, 0x65, 0x12,
+	0x27, 0x0a, 0x0f, 0x63, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x5f, 0x66, 0x61, 0x69, 0x6c, 0x75, 0x72,
+	0x65, 0x73, 0x18, 0x0d, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0e, 0x63, 0x68, 0x61, 0x6e, 0x67, 0x65,
+	0x46, 0x61, 0x69, 0x6c, 0x75, 0x72, 0x65, 0x73, 0x12, 0x2f, 0x0a, 0x13, 0x64, 0x65, 0x70, 0x6c,
+	0x6f, 0x79, 0x6d, 0x65, 0x6e, 0x74, 0x5f, 0x61, 0x74, 0x74, 0x65, 0x6d, 0x70, 0x74, 0x73, 0x18,
+	0x0e, 0x20, 0x01, 0x28, 0x03, 0x52, 0x12, 0x64, 0x65, 0x70, 0x6c, 0x6f, 0x79, 0x6d, 0x65, 0x6e,
+	0x74, 0x41, 0x74, 0x74, 0x65, 0x6d, 0x70, 0x74, 0x73, 0x12, 0x35, 0x0a, 0x16, 0x73, 0x75, 0x63,
+	0x63, 0x65, 0x73, 0x73, 0x66, 0x75, 0x6c, 0x5f, 0x64, 0x65, 0x70, 0x6c, 0x6f, 0x79, 0x6d, 0x65,
+	0x6e, 0x74, 0x73, 0x18, 0x0f, 0x20, 0x01, 0x28, 0x03, 0x52, 0x15, 0x73, 0x75, 0x63, 0x63, 0x65,
+	0x73, 0x73, 0x66, 0x75, 0x6c, 0x44, 0x65, 0x70, 0x6c, 0x6f, 0x79, 0x6d, 0x65, 0x6e, 0x74, 0x73,
+	0x12, 0x2d, 0x0a, 0x12, 0x66, 0x61, 0x69, 0x6c, 0x65, 0x64, 0x5f, 0x64, 0x65, 0x70, 0x6c, 0x6f,
+	0x79, 0x6d, 0x65, 0x6e, 0x74, 0x73, 0x18, 0x10, 0x20, 0x01, 0x28, 0x03, 0x52, 0x11, 0x66, 0x61,
+	0x69, 0x6c, 0x65, 0x64, 0x44, 0x65, 0x70, 0x6c, 0x6f, 0x79, 0x6d, 0x65, 0x6e, 0x74, 0x73, 0x12,
+	0x41, 0x0a, 0x1d, 0x73, 0x65, 0x63, 0x6f, 0x6e, 0x64, 0x73, 0x5f, 0x73, 0x69, 0x6e, 0x63, 0x65,
+	0x5f, 0x6c, 0x61, 0x73, 0x74, 0x5f, 0x64, 0x65, 0x70, 0x6c, 0x6f, 0x79, 0x6d, 0x65, 0x6e, 0x74,
+	0x18, 0x11, 0x20, 0x01, 0x28, 0x01, 0x52, 0x1a, 0x73, 0x65, 0x63, 0x6f, 0x6e, 0x64, 0x73, 0x53,
+	0x69, 0x6e, 0x63, 0x65, 0x4c, 0x61, 0x73, 0x74, 0x44, 0x65, 0x70, 0x6c, 0x6f, 0x79, 0x6d, 0x65,
+	0x6e, 0x74, 0x12, 0x27, 0x0a, 0x0f, 0x63, 0x6f, 0x6d, 0x70, 0x6f, 0x73, 0x69, 0x74, 0x65, 0x5f,
+	0x73, 0x63, 0x6f, 0x72, 0x65, 0x18, 0x12, 0x20, 0x01, 0x28, 0x01, 0x52, 0x0e, 0x63, 0x6f, 0x6d,
+	0x70, 0x6f, 0x73, 0x69, 0x74, 0x65, 0x53, 0x63, 0x6f, 0x72, 0x65, 0x12, 0x35, 0x0a, 0x05, 0x75,
+	0x6e, 0x69, 0x74, 0x73, 0x18, 0x13, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1f, 0x2e, 0x64, 0x6f, 0x72,
+	0x61, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x6f, 0x72, 0x61, 0x4d, 0x65, 0x74, 0x72, 0x69, 0x63, 0x73,
+	0x2e, 0x55, 0x6e, 0x69, 0x74, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x05, 0x75, 0x6e, 0x69,
+	0x74, 0x73, 0x12, 0x31, 0x0a, 0x14, 0x69, 0x6e, 0x73, 0x75, 0x66, 0x66, 0x69, 0x63, 0x69, 0x65,
+	0x6e, 0x74, 0x5f, 0x73, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x73, 0x18, 0x14, 0x20, 0x03, 0x28, 0x09,
+	0x52, 0x13, 0x69, 0x6e, 0x73, 0x75, 0x66, 0x66, 0x69, 0x63, 0x69, 0x65, 0x6e, 0x74, 0x53, 0x61,
+	0x6d, 0x70, 0x6c, 0x65, 0x73, 0x12, 0x38, 0x0a, 0x06, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x73, 0x18,
+	0x15, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x20, 0x2e, 0x64, 0x6f, 0x72, 0x61, 0x2e, 0x76, 0x31, 0x2e,
+	0x44, 0x6f, 0x72, 0x61, 0x4d, 0x65, 0x74, 0x72, 0x69, 0x63, 0x73, 0x2e, 0x45, 0x72, 0x72, 0x6f,
+	0x72, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x06, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x73, 0x12,
+	0x3b, 0x0a, 0x0b, 0x63, 0x6f, 0x6d, 0x70, 0x75, 0x74, 0x65, 0x64, 0x5f, 0x61, 0x74, 0x18, 0x16,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70,
+	0x52, 0x0a, 0x63, 0x6f, 0x6d, 0x70, 0x75, 0x74, 0x65, 0x64, 0x41, 0x74, 0x12, 0x3b, 0x0a, 0x07,
+	0x70, 0x61, 0x72, 0x74, 0x69, 0x61, 0x6c, 0x18, 0x17, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x21, 0x2e,
+	0x64, 0x6f, 0x72, 0x61, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x6f, 0x72, 0x61, 0x4d, 0x65, 0x74, 0x72,
+	0x69, 0x63, 0x73, 0x2e, 0x50, 0x61, 0x72, 0x74, 0x69, 0x61, 0x6c, 0x45, 0x6e, 0x74, 0x72, 0x79,
+	0x52, 0x07, 0x70, 0x61, 0x72, 0x74, 0x69, 0x61, 0x6c, 0x1a, 0x38, 0x0a, 0x0a, 0x55, 0x6e, 0x69,
+	0x74, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c,
+	0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a,
+	0x02, 0x38, 0x01, 0x1a, 0x39, 0x0a, 0x0b, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x73, 0x45, 0x6e, 0x74,
+	0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x1a, 0x3a,
+	0x0a, 0x0c, 0x50, 0x61, 0x72, 0x74, 0x69, 0x61, 0x6c, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10,
+	0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79,
+	0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x32, 0x9d, 0x01, 0x0a, 0x0b, 0x44,
+	0x6f, 0x72, 0x61, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x46, 0x0a, 0x0e, 0x47, 0x65,
+	0x74, 0x44, 0x6f, 0x72, 0x61, 0x4d, 0x65, 0x74, 0x72, 0x69, 0x63, 0x73, 0x12, 0x1e, 0x2e, 0x64,
+	0x6f, 0x72, 0x61, 0x2e, 0x76, 0x31, 0x2e, 0x47, 0x65, 0x74, 0x44, 0x6f, 0x72, 0x61, 0x4d, 0x65,
+	0x74, 0x72, 0x69, 0x63, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x14, 0x2e, 0x64,
+	0x6f, 0x72, 0x61, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x6f, 0x72, 0x61, 0x4d, 0x65, 0x74, 0x72, 0x69,
+	0x63, 0x73, 0x12, 0x46, 0x0a, 0x0d, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x4d, 0x65, 0x74, 0x72,
+	0x69, 0x63, 0x73, 0x12, 0x1d, 0x2e, 0x64, 0x6f, 0x72, 0x61, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x74,
+	0x72, 0x65, 0x61, 0x6d, 0x4d, 0x65, 0x74, 0x72, 0x69, 0x63, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x1a, 0x14, 0x2e, 0x64, 0x6f, 0x72, 0x61, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x6f, 0x72,
+	0x61, 0x4d, 0x65, 0x74, 0x72, 0x69, 0x63, 0x73, 0x30, 0x01, 0x42, 0x0d, 0x5a, 0x0b, 0x64, 0x6f,
+	0x72, 0x61, 0x2f, 0x64, 0x6f, 0x72, 0x61, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x33,
+}
+
+var (
+	file_dorapb_dora_proto_rawDescOnce sync.Once
+	file_dorapb_dora_proto_rawDescData = file_dorapb_dora_proto_rawDesc
+)
+
+func file_dorapb_dora_proto_rawDescGZIP() []byte {
+	file_dorapb_dora_proto_rawDescOnce.Do(func() {
+		file_dorapb_dora_proto_rawDescData = protoimpl.X.CompressGZIP(file_dorapb_dora_proto_rawDescData)
+	})
+	return file_dorapb_dora_proto_rawDescData
+}
+
+var file_dorapb_dora_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_dorapb_dora_proto_goTypes = []any{
+	(*GetDoraMetricsRequest)(nil), // 0: dora.v1.GetDoraMetricsRequest
+	(*StreamMetricsRequest)(nil),  // 1: dora.v1.StreamMetricsRequest
+	(*DoraMetrics)(nil),           // 2: dora.v1.DoraMetrics
+	nil,                           // 3: dora.v1.DoraMetrics.UnitsEntry
+	nil,                           // 4: dora.v1.DoraMetrics.ErrorsEntry
+	nil,                           // 5: dora.v1.DoraMetrics.PartialEntry
+	(*timestamppb.Timestamp)(nil), // 6: google.protobuf.Timestamp
+}
+var file_dorapb_dora_proto_depIdxs = []int32{
+	3, // 0: dora.v1.DoraMetrics.units:type_name -> dora.v1.DoraMetrics.UnitsEntry
+	4, // 1: dora.v1.DoraMetrics.errors:type_name -> dora.v1.DoraMetrics.ErrorsEntry
+	6, // 2: dora.v1.DoraMetrics.computed_at:type_name -> google.protobuf.Timestamp
+	5, // 3: dora.v1.DoraMetrics.partial:type_name -> dora.v1.DoraMetrics.PartialEntry
+	0, // 4: dora.v1.DoraService.GetDoraMetrics:input_type -> dora.v1.GetDoraMetricsRequest
+	1, // 5: dora.v1.DoraService.StreamMetrics:input_type -> dora.v1.StreamMetricsRequest
+	2, // 6: dora.v1.DoraService.GetDoraMetrics:output_type -> dora.v1.DoraMetrics
+	2, // 7: dora.v1.DoraService.StreamMetrics:output_type -> dora.v1.DoraMetrics
+	6, // [6:8] is the sub-list for method output_type
+	4, // [4:6] is the sub-list for method input_type
+	4, // [4:4] is the sub-list for extension type_name
+	4, // [4:4] is the sub-list for extension extendee
+	0, // [0:4] is the sub-list for field type_name
+}
+
+func init() { file_dorapb_dora_proto_init() }
+func file_dorapb_dora_proto_init() {
+	if File_dorapb_dora_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_dorapb_dora_proto_msgTypes[0].Exporter = func(v any, i int) any {
+			switch v := v.(*GetDoraMetricsRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dorapb_dora_proto_msgTypes[1].Exporter = func(v any, i int) any {
+			switch v := v.(*StreamMetricsRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dorapb_dora_proto_msgTypes[2].Exporter = func(v any, i int) any {
+			switch v := v.(*DoraMetrics); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_dorapb_dora_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   6,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_dorapb_dora_proto_goTypes,
+		DependencyIndexes: file_dorapb_dora_proto_depIdxs,
+		MessageInfos:      file_dorapb_dora_proto_msgTypes,
+	}.Build()
+	File_dorapb_dora_proto = out.File
+	file_dorapb_dora_proto_rawDesc = nil
+	file_dorapb_dora_proto_goTypes = nil
+	file_dorapb_dora_proto_depIdxs = nil
+}
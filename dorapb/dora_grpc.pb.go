@@ -0,0 +1,175 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: dorapb/dora.proto
+
+package dorapb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	DoraService_GetDoraMetrics_FullMethodName = "/dora.v1.DoraService/GetDoraMetrics"
+	DoraService_StreamMetrics_FullMethodName  = "/dora.v1.DoraService/StreamMetrics"
+)
+
+// DoraServiceClient is the client API for DoraService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// DoraService serves the DORA metrics of the repo/branches the app tracks.
+type DoraServiceClient interface {
+	// GetDoraMetrics calculates the metrics of a repo/branch, as a webhook for
+	// it would.
+	GetDoraMetrics(ctx context.Context, in *GetDoraMetricsRequest, opts ...grpc.CallOption) (*DoraMetrics, error)
+	// StreamMetrics sends the metrics of each repo/branch every time they are
+	// recomputed, e.g. after a webhook or a refresh.
+	StreamMetrics(ctx context.Context, in *StreamMetricsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DoraMetrics], error)
+}
+
+type doraServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewDoraServiceClient(cc grpc.ClientConnInterface) DoraServiceClient {
+	return &doraServiceClient{cc}
+}
+
+func (c *doraServiceClient) GetDoraMetrics(ctx context.Context, in *GetDoraMetricsRequest, opts ...grpc.CallOption) (*DoraMetrics, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DoraMetrics)
+	err := c.cc.Invoke(ctx, DoraService_GetDoraMetrics_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *doraServiceClient) StreamMetrics(ctx context.Context, in *StreamMetricsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DoraMetrics], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &DoraService_ServiceDesc.Streams[0], DoraService_StreamMetrics_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamMetricsRequest, DoraMetrics]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type DoraService_StreamMetricsClient = grpc.ServerStreamingClient[DoraMetrics]
+
+// DoraServiceServer is the server API for DoraService service.
+// All implementations must embed UnimplementedDoraServiceServer
+// for forward compatibility.
+//
+// DoraService serves the DORA metrics of the repo/branches the app tracks.
+type DoraServiceServer interface {
+	// GetDoraMetrics calculates the metrics of a repo/branch, as a webhook for
+	// it would.
+	GetDoraMetrics(context.Context, *GetDoraMetricsRequest) (*DoraMetrics, error)
+	// StreamMetrics sends the metrics of each repo/branch every time they are
+	// recomputed, e.g. after a webhook or a refresh.
+	StreamMetrics(*StreamMetricsRequest, grpc.ServerStreamingServer[DoraMetrics]) error
+	mustEmbedUnimplementedDoraServiceServer()
+}
+
+// UnimplementedDoraServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedDoraServiceServer struct{}
+
+func (UnimplementedDoraServiceServer) GetDoraMetrics(context.Context, *GetDoraMetricsRequest) (*DoraMetrics, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetDoraMetrics not implemented")
+}
+func (UnimplementedDoraServiceServer) StreamMetrics(*StreamMetricsRequest, grpc.ServerStreamingServer[DoraMetrics]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamMetrics not implemented")
+}
+func (UnimplementedDoraServiceServer) mustEmbedUnimplementedDoraServiceServer() {}
+func (UnimplementedDoraServiceServer) testEmbeddedByValue()                     {}
+
+// UnsafeDoraServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to DoraServiceServer will
+// result in compilation errors.
+type UnsafeDoraServiceServer interface {
+	mustEmbedUnimplementedDoraServiceServer()
+}
+
+func RegisterDoraServiceServer(s grpc.ServiceRegistrar, srv DoraServiceServer) {
+	// If the following call pancis, it indicates UnimplementedDoraServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&DoraService_ServiceDesc, srv)
+}
+
+func _DoraService_GetDoraMetrics_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetDoraMetricsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DoraServiceServer).GetDoraMetrics(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DoraService_GetDoraMetrics_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DoraServiceServer).GetDoraMetrics(ctx, req.(*GetDoraMetricsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DoraService_StreamMetrics_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamMetricsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(DoraServiceServer).StreamMetrics(m, &grpc.GenericServerStream[StreamMetricsRequest, DoraMetrics]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type DoraService_StreamMetricsServer = grpc.ServerStreamingServer[DoraMetrics]
+
+// DoraService_ServiceDesc is the grpc.ServiceDesc for DoraService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var DoraService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "dora.v1.DoraService",
+	HandlerType: (*DoraServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetDoraMetrics",
+			Handler:    _DoraService_GetDoraMetrics_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamMetrics",
+			Handler:       _DoraService_StreamMetrics_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "dorapb/dora.proto",
+}
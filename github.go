@@ -0,0 +1,530 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v45/github"
+)
+
+// githubProvider reads deployments, CI runs and incidents from GitHub.
+type githubProvider struct {
+	client *github.Client
+	// downloadClient fetches artifacts from the pre-signed URLs GitHub
+	// redirects to, which must not receive the GitHub token.
+	downloadClient *http.Client
+}
+
+func newGitHubProvider(client *github.Client, transport http.RoundTripper) *githubProvider {
+	return &githubProvider{
+		client:         client,
+		downloadClient: &http.Client{Transport: transport, Timeout: 30 * time.Second},
+	}
+}
+
+// newGitHubWebhookHandler serves /webhook for GitHub deliveries, recomputing
+// the metrics of the repo/branch an event refers to.
+func newGitHubWebhookHandler(calculator *Calculator, webhookSecrets [][]byte, maxBodyBytes int64, notifier *slackNotifier) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		audit := auditFromContext(r.Context())
+		payload, ok := readWebhookBody(w, r, maxBodyBytes)
+		if !ok {
+			return
+		}
+
+		signature := r.Header.Get(github.SHA256SignatureHeader)
+		if signature == "" {
+			signature = r.Header.Get(github.SHA1SignatureHeader)
+		}
+		if signature == "" {
+			log.Printf("Rejecting webhook without a signature header")
+			webhookSignatureFailures.WithLabelValues("missing").Inc()
+			audit.Signature = "missing"
+			http.Error(w, "missing signature header", http.StatusUnauthorized)
+			return
+		}
+		if err := validateSignatureAny(signature, payload, webhookSecrets); err != nil {
+			log.Printf("Error validating payload: %v", err)
+			webhookSignatureFailures.WithLabelValues("mismatch").Inc()
+			audit.Signature = "mismatch"
+			http.Error(w, "signature mismatch", http.StatusUnauthorized)
+			return
+		}
+		audit.Signature = "valid"
+		// Replayed and re-delivered payloads are acknowledged so that
+		// GitHub does not retry them, but not processed again.
+		w, done, ok := deliveries.processDelivery(w, r.Header.Get("X-GitHub-Delivery"))
+		if !ok {
+			log.Printf("Skipping duplicate delivery %s", r.Header.Get("X-GitHub-Delivery"))
+			w.Write([]byte("Skipped duplicate delivery"))
+			return
+		}
+		defer done()
+
+		event, err := github.ParseWebHook(github.WebHookType(r), payload)
+		if err != nil {
+			log.Printf("Error parsing webhook: %v", err)
+			http.Error(w, "Error parsing webhook", http.StatusBadRequest)
+			return
+		}
+
+		switch e := event.(type) {
+		case *github.PushEvent:
+			log.Printf("Received PushEvent for %s on branch %s", e.Repo.GetFullName(), e.GetRef())
+			branch, ok := getBranchFromRef(e.GetRef())
+			audit.Repo, audit.Branch = e.Repo.GetFullName(), branch
+			if !ok {
+				w.Write([]byte("Skipped tag push"))
+				return
+			}
+			if e.GetDeleted() {
+				forgetSeries(e.Repo.GetFullName(), branch)
+				w.Write([]byte("Removed series of deleted branch"))
+				return
+			}
+			handleMetricsUpdate(calculator, e.Repo.GetFullName(), branch, notifier, w)
+		case *github.WorkflowRunEvent:
+			log.Printf("Received WorkflowRunEvent for %s on branch %s", e.Repo.GetFullName(), e.WorkflowRun.GetHeadBranch())
+			audit.Repo, audit.Branch = e.Repo.GetFullName(), e.WorkflowRun.GetHeadBranch()
+			// Redeliveries and events arriving out of order describe a run
+			// state that the stored metrics were computed after.
+			if computedAfter(e.Repo.GetFullName(), e.WorkflowRun.GetHeadBranch(), e.WorkflowRun.GetUpdatedAt().Time) {
+				log.Printf("Skipping WorkflowRunEvent for %s on branch %s: run updated before the metrics were last computed", e.Repo.GetFullName(), e.WorkflowRun.GetHeadBranch())
+				w.Write([]byte("Skipped event older than the stored metrics"))
+				return
+			}
+			handleMetricsUpdate(calculator, e.Repo.GetFullName(), e.WorkflowRun.GetHeadBranch(), notifier, w)
+		case *github.WorkflowDispatchEvent:
+			// Deploys dispatched by an external system are recomputed as soon
+			// as they are requested, and again by their workflow_run events.
+			branch, _ := getBranchFromRef(e.GetRef())
+			log.Printf("Received WorkflowDispatchEvent for %s on branch %s", e.Repo.GetFullName(), branch)
+			audit.Repo, audit.Branch = e.Repo.GetFullName(), branch
+			if isDeploymentTrigger("workflow_dispatch") && branch != "" {
+				handleMetricsUpdate(calculator, e.Repo.GetFullName(), branch, notifier, w)
+			}
+		case *github.RepositoryDispatchEvent:
+			log.Printf("Received RepositoryDispatchEvent for %s on branch %s", e.Repo.GetFullName(), e.GetBranch())
+			audit.Repo, audit.Branch = e.Repo.GetFullName(), e.GetBranch()
+			if isDeploymentTrigger("repository_dispatch") && e.GetBranch() != "" {
+				handleMetricsUpdate(calculator, e.Repo.GetFullName(), e.GetBranch(), notifier, w)
+			}
+		case *github.PullRequestEvent:
+			pull := e.GetPullRequest()
+			log.Printf("Received PullRequestEvent for %s on branch %s", e.Repo.GetFullName(), pull.GetBase().GetRef())
+			audit.Repo, audit.Branch = e.Repo.GetFullName(), pull.GetBase().GetRef()
+			if e.GetAction() == "closed" && pull.GetMerged() {
+				recordMergedPullRequest(calculator, e.Repo.GetFullName(), pull.GetBase().GetRef(), pull.GetCreatedAt(), pull.GetMergedAt())
+			}
+		case *github.PingEvent:
+			w.Write([]byte("Pong!"))
+		case *github.CheckRunEvent:
+			log.Printf("Received CheckRunEvent for %s on branch %s", e.Repo.GetFullName(), e.CheckRun.GetCheckSuite().GetHeadBranch())
+			audit.Repo, audit.Branch = e.Repo.GetFullName(), e.CheckRun.GetCheckSuite().GetHeadBranch()
+			if cfg.DeploymentSource == deploymentSourceChecks && isDeploymentCheckRun(e.CheckRun.GetName()) && e.CheckRun.GetStatus() == "completed" {
+				handleMetricsUpdate(calculator, e.Repo.GetFullName(), e.CheckRun.GetCheckSuite().GetHeadBranch(), notifier, w)
+			}
+		case *github.ReleaseEvent:
+			// Releases are keyed by tag, in the releaseSeriesBranch series.
+			log.Printf("Received ReleaseEvent for %s on tag %s", e.Repo.GetFullName(), e.Release.GetTagName())
+			audit.Repo, audit.Branch = e.Repo.GetFullName(), releaseSeriesBranch
+			if cfg.DeploymentSource == deploymentSourceReleases && e.GetAction() == "published" {
+				handleMetricsUpdate(calculator, e.Repo.GetFullName(), releaseSeriesBranch, notifier, w)
+			}
+		case *github.StatusEvent:
+			// Statuses are set on commits rather than refs; use the first
+			// branch whose head the commit is.
+			var branch string
+			if len(e.Branches) > 0 {
+				branch = e.Branches[0].GetName()
+			}
+			log.Printf("Received StatusEvent for %s on branch %s", e.Repo.GetFullName(), branch)
+			audit.Repo, audit.Branch = e.Repo.GetFullName(), branch
+			if cfg.DeploymentSource == deploymentSourceStatuses && e.GetContext() == cfg.DeploymentStatusContext && e.GetState() != "pending" {
+				handleMetricsUpdate(calculator, e.Repo.GetFullName(), branch, notifier, w)
+			}
+		case *github.CheckSuiteEvent:
+			log.Printf("Received CheckSuiteEvent for %s on branch %s", e.Repo.GetFullName(), e.CheckSuite.GetHeadBranch())
+			audit.Repo, audit.Branch = e.Repo.GetFullName(), e.CheckSuite.GetHeadBranch()
+		default:
+			log.Printf("Received unhandled event type: %s", github.WebHookType(r))
+			unhandledWebhookEvents.WithLabelValues(github.WebHookType(r)).Inc()
+		}
+	}
+}
+
+// validateSignatureAny accepts the payload if its signature matches any of the
+// configured secrets.
+func validateSignatureAny(signature string, payload []byte, secrets [][]byte) error {
+	var err error
+	for _, secret := range secrets {
+		if err = github.ValidateSignature(signature, payload, secret); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// ListDeploymentAttempts returns the finished deployments of branch created
+// after since, read from the configured DEPLOYMENT_SOURCE.
+func (p *githubProvider) ListDeploymentAttempts(repoFullName string, branch string, since time.Time) ([]deploymentAttempt, error) {
+	switch cfg.DeploymentSource {
+	case deploymentSourceDeployments:
+		deployments, err := p.ListEnvironmentDeployments(repoFullName, branch, "", since)
+		if err != nil {
+			return nil, fmt.Errorf("fetching deployments: %w", err)
+		}
+		return attemptsFromDeploymentResults(deployments), nil
+	case deploymentSourceChecks:
+		return p.listDeploymentAttemptsFromCheckRuns(repoFullName, branch, since)
+	case deploymentSourceReleases:
+		return p.listDeploymentAttemptsFromReleases(repoFullName, branch, since)
+	case deploymentSourceStatuses:
+		return p.listDeploymentAttemptsFromStatuses(repoFullName, branch, since)
+	default:
+		return p.listDeploymentAttemptsFromWorkflowRuns(repoFullName, branch, since)
+	}
+}
+
+// createdSince is the workflow runs "created" filter that leaves out runs
+// created before since, so that they are not fetched at all.
+func createdSince(since time.Time) string {
+	return ">=" + since.UTC().Format(time.RFC3339)
+}
+
+func (p *githubProvider) listDeploymentAttemptsFromWorkflowRuns(repoFullName string, branch string, since time.Time) ([]deploymentAttempt, error) {
+	workflowRuns, listErr := p.listWorkflowRuns(repoFullName, &github.ListWorkflowRunsOptions{
+		Branch:      branch,
+		Created:     createdSince(earliestCreation(since)),
+		ListOptions: github.ListOptions{PerPage: 100},
+	})
+	if listErr != nil && !isPartialList(listErr) {
+		return nil, listErr
+	}
+	activeWorkflows, err := p.activeWorkflowIDs(repoFullName)
+	if err != nil {
+		return nil, err
+	}
+
+	var attempts []deploymentAttempt
+	for _, run := range workflowRuns {
+		// Queued and in-progress runs are counted once they complete.
+		if run.GetStatus() != "completed" || !hasRunTimes(repoFullName, "workflow run", run.GetID(), run.GetCreatedAt().Time, run.GetUpdatedAt().Time) {
+			continue
+		}
+		if !deploymentTime(run.GetCreatedAt().Time, run.GetUpdatedAt().Time).After(since) || !isDeploymentTrigger(run.GetEvent()) {
+			continue
+		}
+		if activeWorkflows != nil && !activeWorkflows[run.GetWorkflowID()] {
+			continue
+		}
+		if isForkRun(run, repoFullName) {
+			continue
+		}
+		class := classifyConclusion(run.GetConclusion())
+		if class == conclusionIgnore {
+			continue
+		}
+		match, ok := matchDeploymentName(run.GetName())
+		if !ok {
+			continue
+		}
+		environment, ok := cfg.WorkflowEnvironments[run.GetName()]
+		if !ok {
+			environment = match.Environment
+		}
+		if environment == "" {
+			environment = defaultEnvironment
+		}
+		version := match.Version
+		if cfg.DeploymentManifestArtifact != "" {
+			manifest, err := p.runManifest(repoFullName, run.GetID())
+			if err != nil {
+				return nil, err
+			}
+			if manifest != nil {
+				if manifest.Environment != "" {
+					environment = manifest.Environment
+				}
+				if manifest.Version != "" {
+					version = manifest.Version
+				}
+			}
+		}
+		attempts = append(attempts, deploymentAttempt{
+			Environment: environment,
+			Version:     version,
+			Workflow:    run.GetName(),
+			HeadSHA:     run.GetHeadSHA(),
+			Actor:       run.GetActor().GetLogin(),
+			CreatedAt:   run.GetCreatedAt().Time,
+			CompletedAt: run.GetUpdatedAt().Time,
+			Successful:  class == conclusionSuccess,
+		})
+	}
+	return attempts, listErr
+}
+
+// isForkRun reports whether run was triggered from a fork, such as a pull
+// request by an external contributor, rather than from repoFullName itself.
+func isForkRun(run *github.WorkflowRun, repoFullName string) bool {
+	head := run.GetHeadRepository().GetFullName()
+	return head != "" && !strings.EqualFold(head, repoFullName)
+}
+
+// activeWorkflowIDs returns the IDs of the repository's enabled workflows when
+// EXCLUDE_INACTIVE_WORKFLOWS is set, and nil otherwise. Deleted workflows are
+// not listed at all and disabled ones have a "disabled_*" state.
+func (p *githubProvider) activeWorkflowIDs(repoFullName string) (map[int64]bool, error) {
+	if !cfg.ExcludeInactiveWorkflows {
+		return nil, nil
+	}
+
+	countGitHubCall("workflows")
+	workflows, _, err := p.client.Actions.ListWorkflows(context.Background(), getOwner(repoFullName), getRepo(repoFullName), &github.ListOptions{PerPage: 100})
+	if err != nil {
+		return nil, fmt.Errorf("fetching workflows: %w", err)
+	}
+	active := make(map[int64]bool, len(workflows.Workflows))
+	for _, workflow := range workflows.Workflows {
+		if workflow.GetState() == "active" {
+			active[workflow.GetID()] = true
+		}
+	}
+	return active, nil
+}
+
+// ListEnvironmentDeployments fetches deployments of branch to environment created
+// after since, oldest first. An empty environment matches every environment.
+func (p *githubProvider) ListEnvironmentDeployments(repoFullName string, branch string, environment string, since time.Time) ([]deploymentResult, error) {
+	ctx := context.Background()
+	owner, repo := getOwner(repoFullName), getRepo(repoFullName)
+
+	countGitHubCall("deployments")
+	deployments, _, err := p.client.Repositories.ListDeployments(ctx, owner, repo, &github.DeploymentsListOptions{
+		Ref:         branch,
+		Environment: environment,
+		ListOptions: github.ListOptions{PerPage: 100},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var results []deploymentResult
+	for _, deployment := range deployments {
+		if !deployment.GetCreatedAt().Time.After(since) {
+			continue
+		}
+
+		// Statuses are returned newest first, so the first one is the current state.
+		countGitHubCall("deployment_statuses")
+		statuses, _, err := p.client.Repositories.ListDeploymentStatuses(ctx, owner, repo, deployment.GetID(), &github.ListOptions{PerPage: 2})
+		if err != nil {
+			return nil, err
+		}
+		if len(statuses) == 0 {
+			continue
+		}
+
+		// An inactive deployment was superseded by a newer one; it finished
+		// when it succeeded, not when it was superseded.
+		finishedAt := statuses[0].GetCreatedAt().Time
+		if statuses[0].GetState() == "inactive" && len(statuses) > 1 && statuses[1].GetState() == "success" {
+			finishedAt = statuses[1].GetCreatedAt().Time
+		}
+		results = append(results, deploymentResult{
+			Environment: deployment.GetEnvironment(),
+			CreatedAt:   deployment.GetCreatedAt().Time,
+			State:       statuses[0].GetState(),
+			FinishedAt:  finishedAt,
+		})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].CreatedAt.Before(results[j].CreatedAt)
+	})
+	return results, nil
+}
+
+func (p *githubProvider) ListPipelineRuns(repoFullName string, branch string, since time.Time) ([]pipelineRun, error) {
+	if cfg.DeploymentSource == deploymentSourceReleases {
+		return p.listPipelineRunsFromReleases(repoFullName, branch, since)
+	}
+
+	workflowRuns, listErr := p.listWorkflowRuns(repoFullName, &github.ListWorkflowRunsOptions{
+		Status:      "completed",
+		Branch:      branch,
+		Created:     createdSince(earliestCreation(since)),
+		ListOptions: github.ListOptions{PerPage: 100},
+	})
+	if listErr != nil && !isPartialList(listErr) {
+		return nil, listErr
+	}
+	activeWorkflows, err := p.activeWorkflowIDs(repoFullName)
+	if err != nil {
+		return nil, err
+	}
+
+	var runs []pipelineRun
+	for _, run := range workflowRuns {
+		if activeWorkflows != nil && !activeWorkflows[run.GetWorkflowID()] {
+			continue
+		}
+		if isForkRun(run, repoFullName) {
+			continue
+		}
+		if _, ok := matchDeploymentName(run.GetName()); !ok {
+			continue
+		}
+		if !hasRunTimes(repoFullName, "workflow run", run.GetID(), run.GetCreatedAt().Time, run.GetUpdatedAt().Time) {
+			continue
+		}
+		if deploymentTime(run.GetCreatedAt().Time, run.GetUpdatedAt().Time).After(since) && isDeploymentTrigger(run.GetEvent()) {
+			runs = append(runs, pipelineRun{
+				CreatedAt:         run.GetCreatedAt().Time,
+				CompletedAt:       run.GetUpdatedAt().Time,
+				Conclusion:        run.GetConclusion(),
+				HeadSHA:           run.GetHeadSHA(),
+				HeadCommitAt:      run.GetHeadCommit().GetTimestamp().Time,
+				HeadCommitMessage: run.GetHeadCommit().GetMessage(),
+			})
+		}
+	}
+	return runs, listErr
+}
+
+func (p *githubProvider) ListIncidents(repoFullName string, since time.Time) ([]incident, error) {
+	countGitHubCall("issues")
+	issues, _, err := p.client.Issues.ListByRepo(context.Background(), getOwner(repoFullName), getRepo(repoFullName), &github.IssueListByRepoOptions{
+		State:       "closed",
+		Labels:      []string{"incident"},
+		Since:       since,
+		ListOptions: github.ListOptions{PerPage: 100},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("fetching incident issues: %w", err)
+	}
+
+	incidents := make([]incident, 0, len(issues))
+	for _, issue := range issues {
+		labels := make([]string, 0, len(issue.Labels))
+		for _, label := range issue.Labels {
+			labels = append(labels, label.GetName())
+		}
+		incidents = append(incidents, incident{
+			Number:    issue.GetNumber(),
+			CreatedAt: issue.GetCreatedAt(),
+			ClosedAt:  issue.GetClosedAt(),
+			Body:      issue.GetBody(),
+			Labels:    labels,
+		})
+	}
+	return incidents, nil
+}
+
+func (p *githubProvider) ListCommitTimes(repoFullName string, base string, head string) ([]time.Time, error) {
+	ctx := context.Background()
+	owner, repo := getOwner(repoFullName), getRepo(repoFullName)
+
+	if base == "" {
+		countGitHubCall("commits")
+		commit, _, err := p.client.Repositories.GetCommit(ctx, owner, repo, head, nil)
+		if err != nil {
+			return nil, fmt.Errorf("fetching commit %s: %w", head, err)
+		}
+		return []time.Time{commit.GetCommit().GetCommitter().GetDate()}, nil
+	}
+
+	countGitHubCall("compare")
+	comparison, _, err := p.client.Repositories.CompareCommits(ctx, owner, repo, base, head, &github.ListOptions{PerPage: 100})
+	if err != nil {
+		return nil, fmt.Errorf("comparing %s...%s: %w", base, head, err)
+	}
+	times := make([]time.Time, 0, len(comparison.Commits))
+	for _, commit := range comparison.Commits {
+		times = append(times, commit.GetCommit().GetCommitter().GetDate())
+	}
+	return times, nil
+}
+
+func (p *githubProvider) ListChangeLabels(repoFullName string, sha string) ([]string, error) {
+	countGitHubCall("pulls")
+	pulls, _, err := p.client.PullRequests.ListPullRequestsWithCommit(context.Background(), getOwner(repoFullName), getRepo(repoFullName), sha, &github.PullRequestListOptions{
+		State:       "all",
+		ListOptions: github.ListOptions{PerPage: 100},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("fetching pull requests of %s: %w", sha, err)
+	}
+
+	var labels []string
+	for _, pull := range pulls {
+		for _, label := range pull.Labels {
+			labels = append(labels, label.GetName())
+		}
+	}
+	return labels, nil
+}
+
+func (p *githubProvider) GetRepository(repoFullName string) (*repositoryInfo, error) {
+	countGitHubCall("repository")
+	repository, _, err := p.client.Repositories.Get(context.Background(), getOwner(repoFullName), getRepo(repoFullName))
+	if err != nil {
+		return nil, fmt.Errorf("fetching repository: %w", err)
+	}
+	return &repositoryInfo{
+		CreatedAt:     repository.GetCreatedAt().Time,
+		DefaultBranch: repository.GetDefaultBranch(),
+	}, nil
+}
+
+// RepositoryURL derives the web host from the API base URL: api.github.com
+// for github.com, or <host>/api/v3 for GitHub Enterprise Server.
+func (p *githubProvider) RepositoryURL(repoFullName string) string {
+	base := *p.client.BaseURL
+	if base.Host == "api.github.com" {
+		base.Host = "github.com"
+	}
+	base.Path = strings.TrimSuffix(strings.TrimSuffix(base.Path, "/"), "/api/v3")
+	return base.String() + "/" + repoFullName
+}
+
+// checkGitHubToken logs the login and OAuth scopes of the token the client
+// authenticates with, warning when the scopes needed to read private
+// repositories appear to be missing. It returns an error only if GitHub
+// rejects the token outright.
+func checkGitHubToken(client *github.Client) error {
+	user, resp, err := client.Users.Get(context.Background(), "")
+	if resp != nil && resp.StatusCode == http.StatusUnauthorized {
+		return fmt.Errorf("GITHUB_TOKEN was rejected by GitHub: %w", err)
+	}
+	if err != nil {
+		// Installation tokens of GitHub Apps cannot read /user but are
+		// otherwise usable.
+		log.Printf("Could not determine the GitHub token owner: %v", err)
+		return nil
+	}
+
+	// Only classic personal access tokens report their scopes; fine-grained
+	// tokens send no X-OAuth-Scopes header.
+	scopes, ok := resp.Header[http.CanonicalHeaderKey("X-OAuth-Scopes")]
+	if !ok {
+		log.Printf("Authenticated to GitHub as %s", user.GetLogin())
+		return nil
+	}
+	log.Printf("Authenticated to GitHub as %s with scopes %q", user.GetLogin(), strings.Join(scopes, ","))
+
+	granted := make(map[string]bool)
+	for _, scope := range strings.Split(strings.Join(scopes, ","), ",") {
+		granted[strings.TrimSpace(scope)] = true
+	}
+	if !granted["repo"] {
+		log.Printf("Warning: GITHUB_TOKEN lacks the repo scope; workflow runs, deployments and issues of private repositories will not be visible")
+	}
+	return nil
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeProvider is a Provider with the given deployment attempts and without
+// any runs or incidents. It records which repo/branches metrics were
+// calculated for.
+type fakeProvider struct {
+	attempts []deploymentAttempt
+
+	mu       sync.Mutex
+	computed []seriesKey
+}
+
+// ListDeploymentAttempts is called by every calculation, so it records the
+// repo/branch being calculated.
+func (p *fakeProvider) ListDeploymentAttempts(repoFullName string, branch string, since time.Time) ([]deploymentAttempt, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	key := seriesKey{Repo: repoFullName, Branch: branch}
+	if !slices.Contains(p.computed, key) {
+		p.computed = append(p.computed, key)
+	}
+	var attempts []deploymentAttempt
+	for _, attempt := range p.attempts {
+		if deploymentTime(attempt.CreatedAt, attempt.CompletedAt).After(since) {
+			attempts = append(attempts, attempt)
+		}
+	}
+	return attempts, nil
+}
+
+func (p *fakeProvider) ListPipelineRuns(repoFullName string, branch string, since time.Time) ([]pipelineRun, error) {
+	return nil, nil
+}
+
+func (p *fakeProvider) ListEnvironmentDeployments(repoFullName string, branch string, environment string, since time.Time) ([]deploymentResult, error) {
+	return nil, nil
+}
+
+func (p *fakeProvider) ListIncidents(repoFullName string, since time.Time) ([]incident, error) {
+	return nil, nil
+}
+
+func (p *fakeProvider) ListCommitTimes(repoFullName string, base string, head string) ([]time.Time, error) {
+	return nil, nil
+}
+
+func (p *fakeProvider) ListChangeLabels(repoFullName string, sha string) ([]string, error) {
+	return nil, nil
+}
+
+func (p *fakeProvider) ListMergedPullRequests(repoFullName string, branch string, since time.Time) ([]mergedPullRequest, error) {
+	return nil, nil
+}
+
+func (p *fakeProvider) ListOpenIncidents(repoFullName string) ([]incident, error) {
+	return nil, nil
+}
+
+func (p *fakeProvider) GetLabeledAt(repoFullName string, number int, label string) (time.Time, error) {
+	return time.Time{}, nil
+}
+
+func (p *fakeProvider) ListChangedFiles(repoFullName string, sha string) ([]string, error) {
+	return nil, nil
+}
+
+func (p *fakeProvider) GetRepository(repoFullName string) (*repositoryInfo, error) {
+	return &repositoryInfo{DefaultBranch: "main"}, nil
+}
+
+func (p *fakeProvider) RepositoryURL(repoFullName string) string {
+	return "https://scm.example.com/" + repoFullName
+}
+
+func (p *fakeProvider) recomputed() []seriesKey {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return slices.Clone(p.computed)
+}
+
+var setupOnce sync.Once
+
+// setupWebhookTest loads the default configuration and registers the metrics
+// once, and gives every test an empty metrics store.
+func setupWebhookTest(t *testing.T) {
+	t.Helper()
+	setupOnce.Do(func() {
+		if err := loadConfig(); err != nil {
+			t.Fatal(err)
+		}
+		registerMetrics()
+		sinks = newMetricsSinks()
+	})
+	seenKeys = newMetricsStore()
+}
+
+const testWebhookSecret = "s3cret"
+
+func githubSignature(payload string) string {
+	mac := hmac.New(sha256.New, []byte(testWebhookSecret))
+	mac.Write([]byte(payload))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestGitHubWebhookHandler(t *testing.T) {
+	recent := time.Now().Add(time.Minute).UTC().Format(time.RFC3339)
+	pushPayload := `{"ref":"refs/heads/main","repository":{"full_name":"acme/api"}}`
+	for _, tc := range []struct {
+		name      string
+		event     string
+		payload   string
+		signature string
+		status    int
+		body      string
+		recompute []seriesKey
+	}{
+		{
+			name:      "push",
+			event:     "push",
+			payload:   pushPayload,
+			status:    http.StatusOK,
+			recompute: []seriesKey{{Repo: "acme/api", Branch: "main"}},
+		},
+		{
+			name:      "tag push",
+			event:     "push",
+			payload:   `{"ref":"refs/tags/v1.0.0","repository":{"full_name":"acme/api"}}`,
+			status:    http.StatusOK,
+			body:      "Skipped tag push",
+			recompute: nil,
+		},
+		{
+			name:      "workflow run completed",
+			event:     "workflow_run",
+			payload:   `{"action":"completed","workflow_run":{"head_branch":"release","status":"completed","conclusion":"success","updated_at":"` + recent + `"},"repository":{"full_name":"acme/api"}}`,
+			status:    http.StatusOK,
+			recompute: []seriesKey{{Repo: "acme/api", Branch: "release"}},
+		},
+		{
+			name:    "ping",
+			event:   "ping",
+			payload: `{"zen":"Keep it logically awesome.","hook_id":1}`,
+			status:  http.StatusOK,
+			body:    "Pong!",
+		},
+		{
+			name:      "invalid signature",
+			event:     "push",
+			payload:   pushPayload,
+			signature: githubSignature(`{"ref":"refs/heads/other"}`),
+			status:    http.StatusUnauthorized,
+		},
+		{
+			name:      "missing signature",
+			event:     "push",
+			payload:   pushPayload,
+			signature: "none",
+			status:    http.StatusUnauthorized,
+		},
+		{
+			name:    "malformed payload",
+			event:   "push",
+			payload: `{"ref":`,
+			status:  http.StatusBadRequest,
+		},
+		{
+			name:    "unsupported event",
+			event:   "star",
+			payload: `{"action":"created","repository":{"full_name":"acme/api"}}`,
+			status:  http.StatusOK,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			setupWebhookTest(t)
+			provider := &fakeProvider{}
+			handler := newGitHubWebhookHandler(newCalculator(provider), [][]byte{[]byte(testWebhookSecret)}, defaultMaxWebhookBodyBytes, nil)
+
+			req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(tc.payload))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-GitHub-Event", tc.event)
+			switch tc.signature {
+			case "":
+				req.Header.Set("X-Hub-Signature-256", githubSignature(tc.payload))
+			case "none":
+			default:
+				req.Header.Set("X-Hub-Signature-256", tc.signature)
+			}
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+
+			if rec.Code != tc.status {
+				t.Errorf("status = %d, want %d (body %q)", rec.Code, tc.status, rec.Body.String())
+			}
+			if tc.body != "" && rec.Body.String() != tc.body {
+				t.Errorf("body = %q, want %q", rec.Body.String(), tc.body)
+			}
+			if got := provider.recomputed(); !slices.Equal(got, tc.recompute) {
+				t.Errorf("recomputed %v, want %v", got, tc.recompute)
+			}
+		})
+	}
+}
+
+func TestGitHubWebhookHandlerSkipsStaleWorkflowRun(t *testing.T) {
+	setupWebhookTest(t)
+	provider := &fakeProvider{}
+	handler := newGitHubWebhookHandler(newCalculator(provider), [][]byte{[]byte(testWebhookSecret)}, defaultMaxWebhookBodyBytes, nil)
+
+	seenKeys.put(seriesKey{Repo: "acme/api", Branch: "main"}, &DoraMetrics{Repo: "acme/api", Branch: "main", ComputedAt: time.Now()})
+	updated := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	payload := `{"action":"completed","workflow_run":{"head_branch":"main","updated_at":"` + updated + `"},"repository":{"full_name":"acme/api"}}`
+	req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-GitHub-Event", "workflow_run")
+	req.Header.Set("X-Hub-Signature-256", githubSignature(payload))
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if got := provider.recomputed(); len(got) != 0 {
+		t.Errorf("recomputed %v, want none", got)
+	}
+}
+
+func TestGitHubWebhookHandlerKeysReleasesByTag(t *testing.T) {
+	setupWebhookTest(t)
+	source := cfg.DeploymentSource
+	cfg.DeploymentSource = deploymentSourceReleases
+	t.Cleanup(func() { cfg.DeploymentSource = source })
+	provider := &fakeProvider{}
+	handler := newGitHubWebhookHandler(newCalculator(provider), [][]byte{[]byte(testWebhookSecret)}, defaultMaxWebhookBodyBytes, nil)
+
+	payload := `{"action":"published","release":{"tag_name":"v1.2.0","target_commitish":"main"},"repository":{"full_name":"acme/api"}}`
+	req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-GitHub-Event", "release")
+	req.Header.Set("X-Hub-Signature-256", githubSignature(payload))
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d (body %q)", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	want := []seriesKey{{Repo: "acme/api", Branch: releaseSeriesBranch}}
+	if got := provider.recomputed(); !slices.Equal(got, want) {
+		t.Errorf("recomputed %v, want %v", got, want)
+	}
+}
+
+func TestGitHubWebhookHandlerProcessesRedeliveryOfFailedDelivery(t *testing.T) {
+	setupWebhookTest(t)
+	cache := deliveries
+	deliveries = newDeliveryCache(10, time.Hour)
+	t.Cleanup(func() { deliveries = cache })
+	handler := newGitHubWebhookHandler(newCalculator(&fakeProvider{}), [][]byte{[]byte(testWebhookSecret)}, defaultMaxWebhookBodyBytes, nil)
+
+	deliver := func(id string, payload string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-GitHub-Event", "push")
+		req.Header.Set("X-GitHub-Delivery", id)
+		req.Header.Set("X-Hub-Signature-256", githubSignature(payload))
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	// The recompute of a malformed repo name fails, so its redelivery is
+	// processed again rather than skipped.
+	failing := `{"ref":"refs/heads/main","repository":{"full_name":"acme"}}`
+	for range 2 {
+		if rec := deliver("failed", failing); rec.Code != http.StatusBadRequest {
+			t.Errorf("status = %d, want %d (body %q)", rec.Code, http.StatusBadRequest, rec.Body.String())
+		}
+	}
+
+	payload := `{"ref":"refs/heads/main","repository":{"full_name":"acme/api"}}`
+	if rec := deliver("ok", payload); rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d (body %q)", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	if rec := deliver("ok", payload); rec.Body.String() != "Skipped duplicate delivery" {
+		t.Errorf("body of redelivery = %q, want %q", rec.Body.String(), "Skipped duplicate delivery")
+	}
+}
+
+func TestGitHubWebhookHandlerRecomputesWorkflowRunWithinClockSkew(t *testing.T) {
+	setupWebhookTest(t)
+	provider := &fakeProvider{}
+	handler := newGitHubWebhookHandler(newCalculator(provider), [][]byte{[]byte(testWebhookSecret)}, defaultMaxWebhookBodyBytes, nil)
+
+	// The local clock ran ahead of GitHub's: the run completed after the
+	// stored calculation started, but is timestamped just before it.
+	computedAt := time.Now()
+	seenKeys.put(seriesKey{Repo: "acme/api", Branch: "main"}, &DoraMetrics{Repo: "acme/api", Branch: "main", ComputedAt: computedAt})
+	updated := computedAt.Add(-5 * time.Second).UTC().Format(time.RFC3339)
+	payload := `{"action":"completed","workflow_run":{"head_branch":"main","status":"completed","conclusion":"success","updated_at":"` + updated + `"},"repository":{"full_name":"acme/api"}}`
+	req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-GitHub-Event", "workflow_run")
+	req.Header.Set("X-Hub-Signature-256", githubSignature(payload))
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+
+	want := []seriesKey{{Repo: "acme/api", Branch: "main"}}
+	if got := provider.recomputed(); !slices.Equal(got, want) {
+		t.Errorf("recomputed %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,482 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// gitlabProvider reads pipelines, deployments and incidents from the GitLab
+// REST API. Projects are identified by their "group/project" path; projects in
+// nested subgroups are not supported.
+type gitlabProvider struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+func newGitLabProvider(baseURL string, token string) *gitlabProvider {
+	return &gitlabProvider{
+		baseURL:    baseURL,
+		token:      token,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// get fetches a GitLab API v4 resource of the project and decodes the JSON
+// response into v.
+func (p *gitlabProvider) get(repoFullName string, resource string, query url.Values, v interface{}) error {
+	_, err := p.getPage(repoFullName, resource, query, v)
+	return err
+}
+
+// getPage is get, also returning the page to request next, or "" on the last
+// page.
+func (p *gitlabProvider) getPage(repoFullName string, resource string, query url.Values, v interface{}) (string, error) {
+	u := fmt.Sprintf("%s/api/v4/projects/%s%s", p.baseURL, url.PathEscape(repoFullName), resource)
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("PRIVATE-TOKEN", p.token)
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		retryAfter, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+		return "", &gitlabRateLimitError{resource: resource, retryAfter: time.Duration(retryAfter) * time.Second}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("GET %s: unexpected status %s", resource, resp.Status)
+	}
+	return resp.Header.Get("X-Next-Page"), json.NewDecoder(resp.Body).Decode(v)
+}
+
+// gitlabRateLimitError is returned for a request GitLab rejected with 429 Too
+// Many Requests.
+type gitlabRateLimitError struct {
+	resource string
+	// retryAfter is the Retry-After GitLab asked for, or 0 if it sent none.
+	retryAfter time.Duration
+}
+
+func (e *gitlabRateLimitError) Error() string {
+	return fmt.Sprintf("GET %s: rate limited", e.resource)
+}
+
+// getAll fetches every page of a GitLab API v4 list resource of the project,
+// following the X-Next-Page header one page at a time, and decodes the items
+// of all of them into v, which must point to a slice. If a page after the
+// first fails, v holds the items of the pages before it and the error is a
+// *partialListError; if the first page fails, v is left as it was.
+func (p *gitlabProvider) getAll(repoFullName string, resource string, query url.Values, v interface{}) error {
+	pageQuery := url.Values{}
+	for key, values := range query {
+		pageQuery[key] = values
+	}
+
+	var items []json.RawMessage
+	var pageErr error
+	for page := 1; ; page++ {
+		var pageItems []json.RawMessage
+		next, err := p.getPage(repoFullName, resource, pageQuery, &pageItems)
+		if err != nil {
+			if page == 1 {
+				return err
+			}
+			pageErr = &partialListError{err: fmt.Errorf("fetching page %d: %w", page, err)}
+			break
+		}
+		items = append(items, pageItems...)
+		if next == "" {
+			break
+		}
+		pageQuery.Set("page", next)
+	}
+
+	all, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(all, v); err != nil {
+		return err
+	}
+	return pageErr
+}
+
+type gitlabPipeline struct {
+	ID     int64  `json:"id"`
+	Name   string `json:"name"`
+	Source string `json:"source"`
+	User   struct {
+		Username string `json:"username"`
+	} `json:"user"`
+	SHA       string    `json:"sha"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// gitlabPipelineConclusions maps the statuses of finished GitLab pipelines to
+// GitHub Actions conclusions. Pipelines in any other status are still running.
+var gitlabPipelineConclusions = map[string]string{
+	"success":  "success",
+	"failed":   "failure",
+	"canceled": "cancelled",
+	"skipped":  "skipped",
+}
+
+func (p *gitlabProvider) listPipelines(repoFullName string, branch string, since time.Time) ([]gitlabPipeline, error) {
+	query := url.Values{
+		"updated_after": {since.Format(time.RFC3339)},
+		"per_page":      {"100"},
+	}
+	if branch != "" {
+		query.Set("ref", branch)
+	}
+
+	var pipelines []gitlabPipeline
+	if err := p.getAll(repoFullName, "/pipelines", query, &pipelines); err != nil {
+		return pipelines, fmt.Errorf("fetching pipelines: %w", err)
+	}
+	return pipelines, nil
+}
+
+// ListDeploymentAttempts returns the finished deployments of branch created
+// after since, read from the configured DEPLOYMENT_SOURCE. Pipelines take the
+// place of workflow runs.
+func (p *gitlabProvider) ListDeploymentAttempts(repoFullName string, branch string, since time.Time) ([]deploymentAttempt, error) {
+	if cfg.DeploymentSource == deploymentSourceDeployments {
+		deployments, err := p.ListEnvironmentDeployments(repoFullName, branch, "", since)
+		if err != nil && !isPartialList(err) {
+			return nil, err
+		}
+		return attemptsFromDeploymentResults(deployments), err
+	}
+
+	pipelines, listErr := p.listPipelines(repoFullName, branch, since)
+	if listErr != nil && !isPartialList(listErr) {
+		return nil, listErr
+	}
+
+	var attempts []deploymentAttempt
+	for _, pipeline := range pipelines {
+		conclusion, finished := gitlabPipelineConclusions[pipeline.Status]
+		if !finished || !hasRunTimes(repoFullName, "pipeline", pipeline.ID, pipeline.CreatedAt, pipeline.UpdatedAt) {
+			continue
+		}
+		if !deploymentTime(pipeline.CreatedAt, pipeline.UpdatedAt).After(since) || !isDeploymentTrigger(pipeline.Source) {
+			continue
+		}
+		class := classifyConclusion(conclusion)
+		if class == conclusionIgnore {
+			continue
+		}
+		environment, ok := cfg.WorkflowEnvironments[pipeline.Name]
+		if !ok {
+			environment = defaultEnvironment
+		}
+		attempts = append(attempts, deploymentAttempt{
+			Environment: environment,
+			Workflow:    pipeline.Name,
+			HeadSHA:     pipeline.SHA,
+			Actor:       pipeline.User.Username,
+			CreatedAt:   pipeline.CreatedAt,
+			CompletedAt: pipeline.UpdatedAt,
+			Successful:  class == conclusionSuccess,
+		})
+	}
+	return attempts, listErr
+}
+
+func (p *gitlabProvider) ListPipelineRuns(repoFullName string, branch string, since time.Time) ([]pipelineRun, error) {
+	pipelines, listErr := p.listPipelines(repoFullName, branch, since)
+	if listErr != nil && !isPartialList(listErr) {
+		return nil, listErr
+	}
+
+	var runs []pipelineRun
+	for _, pipeline := range pipelines {
+		conclusion, finished := gitlabPipelineConclusions[pipeline.Status]
+		if !finished || !hasRunTimes(repoFullName, "pipeline", pipeline.ID, pipeline.CreatedAt, pipeline.UpdatedAt) {
+			continue
+		}
+		if deploymentTime(pipeline.CreatedAt, pipeline.UpdatedAt).After(since) && isDeploymentTrigger(pipeline.Source) {
+			runs = append(runs, pipelineRun{
+				CreatedAt:   pipeline.CreatedAt,
+				CompletedAt: pipeline.UpdatedAt,
+				Conclusion:  conclusion,
+				HeadSHA:     pipeline.SHA,
+			})
+		}
+	}
+	return runs, listErr
+}
+
+type gitlabDeployment struct {
+	Ref         string    `json:"ref"`
+	Status      string    `json:"status"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Environment struct {
+		Name string `json:"name"`
+	} `json:"environment"`
+}
+
+// gitlabDeploymentStates maps GitLab deployment statuses to GitHub deployment
+// states. Canceled deployments neither succeeded nor failed, and are left out
+// rather than mapped to "inactive", which means superseded after success.
+var gitlabDeploymentStates = map[string]string{
+	"created": "pending",
+	"blocked": "pending",
+	"running": "in_progress",
+	"success": "success",
+	"failed":  "failure",
+}
+
+func (p *gitlabProvider) ListEnvironmentDeployments(repoFullName string, branch string, environment string, since time.Time) ([]deploymentResult, error) {
+	query := url.Values{
+		"updated_after": {since.Format(time.RFC3339)},
+		"order_by":      {"updated_at"},
+		"sort":          {"asc"},
+		"per_page":      {"100"},
+	}
+	if environment != "" {
+		query.Set("environment", environment)
+	}
+
+	var deployments []gitlabDeployment
+	listErr := p.getAll(repoFullName, "/deployments", query, &deployments)
+	if listErr != nil {
+		listErr = fmt.Errorf("fetching deployments: %w", listErr)
+		if !isPartialList(listErr) {
+			return nil, listErr
+		}
+	}
+
+	var results []deploymentResult
+	for _, deployment := range deployments {
+		if (branch != "" && deployment.Ref != branch) || !deployment.CreatedAt.After(since) {
+			continue
+		}
+		results = append(results, deploymentResult{
+			Environment: deployment.Environment.Name,
+			CreatedAt:   deployment.CreatedAt,
+			State:       gitlabDeploymentStates[deployment.Status],
+			FinishedAt:  deployment.UpdatedAt,
+		})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].CreatedAt.Before(results[j].CreatedAt)
+	})
+	return results, listErr
+}
+
+type gitlabIssue struct {
+	IID         int       `json:"iid"`
+	Description string    `json:"description"`
+	Labels      []string  `json:"labels"`
+	CreatedAt   time.Time `json:"created_at"`
+	ClosedAt    time.Time `json:"closed_at"`
+}
+
+func (p *gitlabProvider) ListIncidents(repoFullName string, since time.Time) ([]incident, error) {
+	var issues []gitlabIssue
+	listErr := p.getAll(repoFullName, "/issues", url.Values{
+		"state":         {"closed"},
+		"labels":        {"incident"},
+		"updated_after": {since.Format(time.RFC3339)},
+		"per_page":      {"100"},
+	}, &issues)
+	if listErr != nil {
+		listErr = fmt.Errorf("fetching incident issues: %w", listErr)
+		if !isPartialList(listErr) {
+			return nil, listErr
+		}
+	}
+
+	incidents := make([]incident, 0, len(issues))
+	for _, issue := range issues {
+		incidents = append(incidents, incident{
+			Number:    issue.IID,
+			CreatedAt: issue.CreatedAt,
+			ClosedAt:  issue.ClosedAt,
+			Body:      issue.Description,
+			Labels:    issue.Labels,
+		})
+	}
+	return incidents, listErr
+}
+
+type gitlabCommit struct {
+	CommittedDate time.Time `json:"committed_date"`
+}
+
+func (p *gitlabProvider) ListCommitTimes(repoFullName string, base string, head string) ([]time.Time, error) {
+	if base == "" {
+		var commit gitlabCommit
+		if err := p.get(repoFullName, "/repository/commits/"+url.PathEscape(head), nil, &commit); err != nil {
+			return nil, fmt.Errorf("fetching commit %s: %w", head, err)
+		}
+		return []time.Time{commit.CommittedDate}, nil
+	}
+
+	var comparison struct {
+		Commits []gitlabCommit `json:"commits"`
+	}
+	if err := p.get(repoFullName, "/repository/compare", url.Values{"from": {base}, "to": {head}}, &comparison); err != nil {
+		return nil, fmt.Errorf("comparing %s...%s: %w", base, head, err)
+	}
+	times := make([]time.Time, 0, len(comparison.Commits))
+	for _, commit := range comparison.Commits {
+		times = append(times, commit.CommittedDate)
+	}
+	return times, nil
+}
+
+func (p *gitlabProvider) ListChangeLabels(repoFullName string, sha string) ([]string, error) {
+	var mergeRequests []struct {
+		Labels []string `json:"labels"`
+	}
+	if err := p.get(repoFullName, "/repository/commits/"+url.PathEscape(sha)+"/merge_requests", nil, &mergeRequests); err != nil {
+		return nil, fmt.Errorf("fetching merge requests of %s: %w", sha, err)
+	}
+
+	var labels []string
+	for _, mergeRequest := range mergeRequests {
+		labels = append(labels, mergeRequest.Labels...)
+	}
+	return labels, nil
+}
+
+func (p *gitlabProvider) RepositoryURL(repoFullName string) string {
+	return p.baseURL + "/" + repoFullName
+}
+
+func (p *gitlabProvider) GetRepository(repoFullName string) (*repositoryInfo, error) {
+	var project struct {
+		CreatedAt     time.Time `json:"created_at"`
+		DefaultBranch string    `json:"default_branch"`
+	}
+	if err := p.get(repoFullName, "", nil, &project); err != nil {
+		return nil, fmt.Errorf("fetching project: %w", err)
+	}
+	return &repositoryInfo{
+		CreatedAt:     project.CreatedAt,
+		DefaultBranch: project.DefaultBranch,
+	}, nil
+}
+
+// gitlabWebhookEvent holds the fields used from GitLab push, pipeline and
+// deployment hooks.
+type gitlabWebhookEvent struct {
+	Ref              string `json:"ref"`
+	After            string `json:"after"`
+	ObjectAttributes struct {
+		Ref string `json:"ref"`
+	} `json:"object_attributes"`
+	Project struct {
+		PathWithNamespace string `json:"path_with_namespace"`
+	} `json:"project"`
+}
+
+// newGitLabWebhookHandler serves /webhook for GitLab deliveries. GitLab sends
+// the configured secret token verbatim in the X-Gitlab-Token header rather than
+// signing the payload.
+func newGitLabWebhookHandler(calculator *Calculator, webhookSecrets [][]byte, maxBodyBytes int64, notifier *slackNotifier) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		audit := auditFromContext(r.Context())
+		payload, ok := readWebhookBody(w, r, maxBodyBytes)
+		if !ok {
+			return
+		}
+
+		token := r.Header.Get("X-Gitlab-Token")
+		if token == "" {
+			log.Printf("Rejecting webhook without a token header")
+			webhookSignatureFailures.WithLabelValues("missing").Inc()
+			audit.Signature = "missing"
+			http.Error(w, "missing token header", http.StatusUnauthorized)
+			return
+		}
+		if !matchesAnySecret([]byte(token), webhookSecrets) {
+			log.Printf("Rejecting webhook with an unknown token")
+			webhookSignatureFailures.WithLabelValues("mismatch").Inc()
+			audit.Signature = "mismatch"
+			http.Error(w, "token mismatch", http.StatusUnauthorized)
+			return
+		}
+		audit.Signature = "valid"
+		w, done, ok := deliveries.processDelivery(w, r.Header.Get("X-Gitlab-Event-UUID"))
+		if !ok {
+			log.Printf("Skipping duplicate delivery %s", r.Header.Get("X-Gitlab-Event-UUID"))
+			w.Write([]byte("Skipped duplicate delivery"))
+			return
+		}
+		defer done()
+
+		var event gitlabWebhookEvent
+		if err := json.Unmarshal(payload, &event); err != nil {
+			log.Printf("Error parsing webhook: %v", err)
+			http.Error(w, "Error parsing webhook", http.StatusBadRequest)
+			return
+		}
+
+		eventType := r.Header.Get("X-Gitlab-Event")
+		repoFullName := event.Project.PathWithNamespace
+		audit.Repo = repoFullName
+		switch eventType {
+		case "Push Hook":
+			log.Printf("Received %s for %s on branch %s", eventType, repoFullName, event.Ref)
+			branch, ok := getBranchFromRef(event.Ref)
+			audit.Branch = branch
+			if !ok {
+				w.Write([]byte("Skipped tag push"))
+				return
+			}
+			// GitLab reports a deleted branch as a push to the zero SHA.
+			if strings.Trim(event.After, "0") == "" && event.After != "" {
+				forgetSeries(repoFullName, branch)
+				w.Write([]byte("Removed series of deleted branch"))
+				return
+			}
+			handleMetricsUpdate(calculator, repoFullName, branch, notifier, w)
+		case "Pipeline Hook":
+			log.Printf("Received %s for %s on branch %s", eventType, repoFullName, event.ObjectAttributes.Ref)
+			audit.Branch = event.ObjectAttributes.Ref
+			handleMetricsUpdate(calculator, repoFullName, event.ObjectAttributes.Ref, notifier, w)
+		case "Deployment Hook":
+			log.Printf("Received %s for %s on branch %s", eventType, repoFullName, event.Ref)
+			audit.Branch = event.Ref
+			handleMetricsUpdate(calculator, repoFullName, event.Ref, notifier, w)
+		default:
+			log.Printf("Received unhandled event type: %s", eventType)
+			unhandledWebhookEvents.WithLabelValues(eventType).Inc()
+		}
+	}
+}
+
+func matchesAnySecret(token []byte, secrets [][]byte) bool {
+	for _, secret := range secrets {
+		if subtle.ConstantTimeCompare(token, secret) == 1 {
+			return true
+		}
+	}
+	return false
+}
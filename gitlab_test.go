@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGitLabGetAllFollowsNextPage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch page := r.URL.Query().Get("page"); page {
+		case "":
+			w.Header().Set("X-Next-Page", "2")
+			fmt.Fprint(w, `[{"iid":1},{"iid":2}]`)
+		case "2":
+			w.Header().Set("X-Next-Page", "3")
+			fmt.Fprint(w, `[{"iid":3}]`)
+		default:
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+	provider := newGitLabProvider(server.URL, "token")
+
+	var issues []gitlabIssue
+	err := provider.getAll("acme/api", "/issues", nil, &issues)
+	if !isPartialList(err) {
+		t.Fatalf("err = %v, want a partial listing", err)
+	}
+	if len(issues) != 3 {
+		t.Fatalf("got %d issues, want the 3 of the pages before the failed one", len(issues))
+	}
+	for i, issue := range issues {
+		if issue.IID != i+1 {
+			t.Errorf("issues[%d].IID = %d, want %d", i, issue.IID, i+1)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestGitLabWebhookHandler(t *testing.T) {
+	pushPayload := `{"ref":"refs/heads/main","after":"1a2b3c","project":{"path_with_namespace":"acme/api"}}`
+	for _, tc := range []struct {
+		name      string
+		event     string
+		payload   string
+		token     string
+		status    int
+		body      string
+		recompute []seriesKey
+	}{
+		{
+			name:      "push",
+			event:     "Push Hook",
+			payload:   pushPayload,
+			token:     testWebhookSecret,
+			status:    http.StatusOK,
+			recompute: []seriesKey{{Repo: "acme/api", Branch: "main"}},
+		},
+		{
+			name:    "deleted branch",
+			event:   "Push Hook",
+			payload: `{"ref":"refs/heads/old","after":"0000000000000000000000000000000000000000","project":{"path_with_namespace":"acme/api"}}`,
+			token:   testWebhookSecret,
+			status:  http.StatusOK,
+			body:    "Removed series of deleted branch",
+		},
+		{
+			name:      "pipeline",
+			event:     "Pipeline Hook",
+			payload:   `{"object_attributes":{"ref":"release"},"project":{"path_with_namespace":"acme/api"}}`,
+			token:     testWebhookSecret,
+			status:    http.StatusOK,
+			recompute: []seriesKey{{Repo: "acme/api", Branch: "release"}},
+		},
+		{
+			name:      "deployment",
+			event:     "Deployment Hook",
+			payload:   `{"ref":"main","project":{"path_with_namespace":"acme/api"}}`,
+			token:     testWebhookSecret,
+			status:    http.StatusOK,
+			recompute: []seriesKey{{Repo: "acme/api", Branch: "main"}},
+		},
+		{
+			name:    "invalid token",
+			event:   "Push Hook",
+			payload: pushPayload,
+			token:   "wrong",
+			status:  http.StatusUnauthorized,
+		},
+		{
+			name:    "missing token",
+			event:   "Push Hook",
+			payload: pushPayload,
+			status:  http.StatusUnauthorized,
+		},
+		{
+			name:    "malformed payload",
+			event:   "Push Hook",
+			payload: `{"ref":`,
+			token:   testWebhookSecret,
+			status:  http.StatusBadRequest,
+		},
+		{
+			name:    "unsupported event",
+			event:   "Note Hook",
+			payload: `{"project":{"path_with_namespace":"acme/api"}}`,
+			token:   testWebhookSecret,
+			status:  http.StatusOK,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			setupWebhookTest(t)
+			provider := &fakeProvider{}
+			handler := newGitLabWebhookHandler(newCalculator(provider), [][]byte{[]byte(testWebhookSecret)}, defaultMaxWebhookBodyBytes, nil)
+
+			req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(tc.payload))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-Gitlab-Event", tc.event)
+			if tc.token != "" {
+				req.Header.Set("X-Gitlab-Token", tc.token)
+			}
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+
+			if rec.Code != tc.status {
+				t.Errorf("status = %d, want %d (body %q)", rec.Code, tc.status, rec.Body.String())
+			}
+			if tc.body != "" && rec.Body.String() != tc.body {
+				t.Errorf("body = %q, want %q", rec.Body.String(), tc.body)
+			}
+			if got := provider.recomputed(); !slices.Equal(got, tc.recompute) {
+				t.Errorf("recomputed %v, want %v", got, tc.recompute)
+			}
+		})
+	}
+}
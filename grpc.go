@@ -0,0 +1,183 @@
+package main
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative dorapb/dora.proto
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+
+	"dora/dorapb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// streamBufferSize is how many updates a StreamMetrics client can fall
+// behind before updates for it are dropped.
+const streamBufferSize = 16
+
+// metricsBroadcaster is a MetricsSink that hands every published result to
+// the StreamMetrics clients.
+type metricsBroadcaster struct {
+	mu          sync.Mutex
+	subscribers map[chan *DoraMetrics]bool
+}
+
+func newMetricsBroadcaster() *metricsBroadcaster {
+	return &metricsBroadcaster{subscribers: make(map[chan *DoraMetrics]bool)}
+}
+
+// subscribe returns a channel receiving every published result and a
+// function that stops the subscription.
+func (b *metricsBroadcaster) subscribe() (<-chan *DoraMetrics, func()) {
+	ch := make(chan *DoraMetrics, streamBufferSize)
+	b.mu.Lock()
+	b.subscribers[ch] = true
+	b.mu.Unlock()
+	return ch, func() {
+		b.mu.Lock()
+		delete(b.subscribers, ch)
+		b.mu.Unlock()
+	}
+}
+
+// Publish never blocks a recompute on a slow client; the update is dropped
+// for that client instead.
+func (b *metricsBroadcaster) Publish(metrics *DoraMetrics) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subscribers {
+		select {
+		case ch <- metrics:
+		default:
+			log.Printf("Dropping metrics update for %s on branch %s: stream client is too slow", metrics.Repo, metrics.Branch)
+		}
+	}
+	return nil
+}
+
+// grpcServer implements dorapb.DoraServiceServer on top of the same
+// recompute path as the webhooks.
+type grpcServer struct {
+	dorapb.UnimplementedDoraServiceServer
+	calculator  *Calculator
+	notifier    *slackNotifier
+	broadcaster *metricsBroadcaster
+}
+
+func (s *grpcServer) GetDoraMetrics(ctx context.Context, req *dorapb.GetDoraMetricsRequest) (*dorapb.DoraMetrics, error) {
+	if req.GetBranch() == "" {
+		return nil, status.Error(codes.InvalidArgument, "missing branch")
+	}
+	metrics, err := recomputeMetrics(ctx, s.calculator, req.GetRepo(), req.GetBranch(), s.notifier)
+	if errors.Is(err, errInvalidRepoFullName) {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err != nil {
+		log.Printf("Error calculating DORA metrics: %v", err)
+		return nil, status.Error(codes.Internal, "error calculating DORA metrics")
+	}
+	return doraMetricsToProto(metrics), nil
+}
+
+func (s *grpcServer) StreamMetrics(req *dorapb.StreamMetricsRequest, stream grpc.ServerStreamingServer[dorapb.DoraMetrics]) error {
+	updates, unsubscribe := s.broadcaster.subscribe()
+	defer unsubscribe()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case metrics := <-updates:
+			if req.GetRepo() != "" && req.GetRepo() != metrics.Repo {
+				continue
+			}
+			if req.GetBranch() != "" && req.GetBranch() != metrics.Branch {
+				continue
+			}
+			if err := stream.Send(doraMetricsToProto(metrics)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func doraMetricsToProto(metrics *DoraMetrics) *dorapb.DoraMetrics {
+	return &dorapb.DoraMetrics{
+		Repo:                       metrics.Repo,
+		Branch:                     metrics.Branch,
+		DeploymentFrequency:        metrics.DeploymentFrequency,
+		DeploymentFrequencyDays:    metrics.DeploymentFrequencyDays,
+		LeadTimeForChanges:         metrics.LeadTimeForChanges,
+		LeadTimeForNormalChanges:   metrics.LeadTimeForNormalChanges,
+		LeadTimeForHotfixes:        metrics.LeadTimeForHotfixes,
+		LeadTimeSampleCount:        int64(metrics.LeadTimeSampleCount),
+		HotfixCount:                int64(metrics.HotfixCount),
+		TimeToRestoreService:       metrics.TimeToRestoreService,
+		IncidentCount:              int64(metrics.IncidentCount),
+		ChangeFailureRate:          metrics.ChangeFailureRate,
+		ChangeFailures:             int64(metrics.ChangeFailures),
+		DeploymentAttempts:         int64(metrics.DeploymentAttempts),
+		SuccessfulDeployments:      int64(metrics.SuccessfulDeployments),
+		FailedDeployments:          int64(metrics.FailedDeployments),
+		SecondsSinceLastDeployment: metrics.SecondsSinceLastDeployment,
+		CompositeScore:             metrics.CompositeScore,
+		Units:                      metrics.Units,
+		InsufficientSamples:        metrics.InsufficientSamples,
+		Errors:                     metrics.Errors,
+		Partial:                    metrics.Partial,
+		ComputedAt:                 timestamppb.New(metrics.ComputedAt),
+	}
+}
+
+// serveGRPC serves the DoraService on addr, separately from the webhook and
+// metrics listener. Calls require the same credentials as the query endpoints.
+func serveGRPC(addr string, calculator *Calculator, notifier *slackNotifier, broadcaster *metricsBroadcaster, credentials apiCredentials) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	server := grpc.NewServer(grpcAuthOptions(credentials)...)
+	dorapb.RegisterDoraServiceServer(server, &grpcServer{calculator: calculator, notifier: notifier, broadcaster: broadcaster})
+
+	log.Printf("Serving gRPC on %s", addr)
+	log.Fatal(server.Serve(listener))
+}
+
+// grpcAuthOptions returns interceptors rejecting calls whose "authorization"
+// metadata does not carry the credentials, as a bearer token or basic auth
+// like the HTTP header. It returns none when no credentials are configured.
+func grpcAuthOptions(credentials apiCredentials) []grpc.ServerOption {
+	if !credentials.enabled() {
+		return nil
+	}
+	authorize := func(ctx context.Context) error {
+		md, _ := metadata.FromIncomingContext(ctx)
+		r := &http.Request{Header: http.Header{"Authorization": md.Get("authorization")}}
+		if !credentials.allows(r) {
+			return status.Error(codes.Unauthenticated, "unauthorized")
+		}
+		return nil
+	}
+	return []grpc.ServerOption{
+		grpc.UnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			if err := authorize(ctx); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if err := authorize(stream.Context()); err != nil {
+				return err
+			}
+			return handler(srv, stream)
+		}),
+	}
+}
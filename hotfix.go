@@ -0,0 +1,85 @@
+package main
+
+import (
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// maxHotfixLabelCacheEntries bounds the memory used by hotfixLabelCache.
+const maxHotfixLabelCacheEntries = 10000
+
+// hotfixLabelCache remembers, by repo and commit, the commits whose pull
+// requests carry one of HOTFIX_LABELS, so that hotfixes are only looked up
+// once. Commits without one are looked up again, as the label may be added to
+// their pull request later. It is emptied once it grows too large.
+var hotfixLabelCache = struct {
+	mu       sync.Mutex
+	hotfixes map[string]bool
+}{hotfixes: make(map[string]bool)}
+
+// mergedBranchPattern extracts the source branch from the messages of merge
+// commits created by GitHub ("Merge pull request #1 from owner/branch") and
+// GitLab ("Merge branch 'branch' into 'main'").
+var mergedBranchPattern = regexp.MustCompile(`^Merge (?:pull request #\d+ from [^/\s]+/(\S+)|branch '([^']+)')`)
+
+// isHotfix reports whether run deployed a hotfix: its head commit message
+// starts with one of HOTFIX_COMMIT_PREFIXES, it merged a branch matching
+// HOTFIX_BRANCH_PATTERN, or one of its pull requests carries one of
+// HOTFIX_LABELS. Labels are only looked up when HOTFIX_LABELS is set.
+func isHotfix(provider Provider, repoFullName string, run pipelineRun) (bool, error) {
+	for _, prefix := range cfg.HotfixCommitPrefixes {
+		if strings.HasPrefix(run.HeadCommitMessage, prefix) {
+			return true, nil
+		}
+	}
+
+	if cfg.HotfixBranchPattern != "" {
+		if m := mergedBranchPattern.FindStringSubmatch(run.HeadCommitMessage); m != nil {
+			branch := m[1] + m[2]
+			if matched, _ := path.Match(cfg.HotfixBranchPattern, branch); matched {
+				return true, nil
+			}
+		}
+	}
+
+	if len(cfg.HotfixLabels) == 0 || run.HeadSHA == "" {
+		return false, nil
+	}
+	return hasHotfixLabel(provider, repoFullName, run.HeadSHA)
+}
+
+// hasHotfixLabel reports whether a pull request containing the commit sha
+// carries one of HOTFIX_LABELS, caching hotfixes in hotfixLabelCache.
+func hasHotfixLabel(provider Provider, repoFullName string, sha string) (bool, error) {
+	key := repoFullName + "@" + sha
+	hotfixLabelCache.mu.Lock()
+	hotfix := hotfixLabelCache.hotfixes[key]
+	hotfixLabelCache.mu.Unlock()
+	if hotfix {
+		return true, nil
+	}
+
+	labels, err := provider.ListChangeLabels(repoFullName, sha)
+	if err != nil {
+		return false, err
+	}
+	for _, label := range labels {
+		if cfg.HotfixLabels[label] {
+			hotfix = true
+			break
+		}
+	}
+	if !hotfix {
+		return false, nil
+	}
+
+	hotfixLabelCache.mu.Lock()
+	if len(hotfixLabelCache.hotfixes) >= maxHotfixLabelCacheEntries {
+		hotfixLabelCache.hotfixes = make(map[string]bool)
+	}
+	hotfixLabelCache.hotfixes[key] = true
+	hotfixLabelCache.mu.Unlock()
+	return true, nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import "testing"
+
+// labelCountingProvider returns labels for every commit and counts the pull
+// request label lookups.
+type labelCountingProvider struct {
+	fakeProvider
+	labels     []string
+	labelCalls int
+}
+
+func (p *labelCountingProvider) ListChangeLabels(repoFullName string, sha string) ([]string, error) {
+	p.labelCalls++
+	return p.labels, nil
+}
+
+func TestIsHotfixLooksUpLabelsUntilHotfix(t *testing.T) {
+	setupWebhookTest(t)
+	labels := cfg.HotfixLabels
+	cfg.HotfixLabels = map[string]bool{"hotfix": true}
+	t.Cleanup(func() { cfg.HotfixLabels = labels })
+	provider := &labelCountingProvider{}
+	run := pipelineRun{HeadSHA: "1a2b3c"}
+
+	// The label is added to the pull request after the first lookup.
+	for _, want := range []bool{false, true, true} {
+		hotfix, err := isHotfix(provider, "acme/cached", run)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hotfix != want {
+			t.Errorf("hotfix = %t, want %t", hotfix, want)
+		}
+		provider.labels = []string{"hotfix"}
+	}
+	if provider.labelCalls != 2 {
+		t.Errorf("labels looked up %d times, want 2", provider.labelCalls)
+	}
+}
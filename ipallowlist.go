@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// parsePrefixList parses a comma-separated list of CIDR ranges. A bare IP
+// address is treated as a single-address range.
+func parsePrefixList(list string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, s := range strings.Split(list, ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		if !strings.Contains(s, "/") {
+			addr, err := netip.ParseAddr(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid address %q: %w", s, err)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q: %w", s, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+func prefixesContain(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientAddr returns the address a request came from. When the connection is
+// from a trusted proxy, X-Forwarded-For is walked from the right and the
+// first address that is not itself a trusted proxy is returned.
+func clientAddr(r *http.Request, trustedProxies []netip.Prefix) (netip.Addr, error) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("parsing remote address %q: %w", r.RemoteAddr, err)
+	}
+	addr = addr.Unmap()
+
+	if !prefixesContain(trustedProxies, addr) {
+		return addr, nil
+	}
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			return netip.Addr{}, fmt.Errorf("parsing X-Forwarded-For address %q: %w", hops[i], err)
+		}
+		addr = hop.Unmap()
+		if !prefixesContain(trustedProxies, addr) {
+			break
+		}
+	}
+	return addr, nil
+}
+
+// withIPAllowlist rejects requests whose client address is not in allowlist
+// with 403 Forbidden. An empty allowlist accepts every request.
+func withIPAllowlist(next http.HandlerFunc, allowlist []netip.Prefix, trustedProxies []netip.Prefix) http.HandlerFunc {
+	if len(allowlist) == 0 {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		addr, err := clientAddr(r, trustedProxies)
+		if err != nil {
+			log.Printf("Rejecting webhook: %v", err)
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		if !prefixesContain(allowlist, addr) {
+			log.Printf("Rejecting webhook from %s: not in WEBHOOK_IP_ALLOWLIST", addr)
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
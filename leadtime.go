@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// leadTimeModeRunDuration measures from when the run was created.
+	leadTimeModeRunDuration = "run_duration"
+	// leadTimeModeHeadCommit measures from the run's head commit.
+	leadTimeModeHeadCommit = "head_commit"
+	// leadTimeModeOldestCommit measures from the oldest commit shipped since
+	// the previous successful run, i.e. every commit of the pushes or pull
+	// requests that the deployment released.
+	leadTimeModeOldestCommit = "oldest_commit"
+	// leadTimeModeDeployInterval measures from the head commit of the
+	// previous successful run. It approximates the lead time of the batch of
+	// changes a deployment released without any per-commit lookups.
+	leadTimeModeDeployInterval = "deploy_interval"
+)
+
+// Values accepted in LEAD_TIME_DISTRIBUTION.
+const (
+	leadTimeDistributionHistogram = "histogram"
+	leadTimeDistributionSummary   = "summary"
+)
+
+var (
+	// leadTimeHistogramBuckets spans five minutes to two weeks, in minutes.
+	leadTimeHistogramBuckets  = []float64{5, 15, 30, 60, 120, 240, 480, 1440, 2880, 10080, 20160}
+	leadTimeSummaryObjectives = map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001}
+)
+
+// leadTimeObservations tracks the runs observed into the lead time
+// distributions.
+var leadTimeObservations = newObservationSets()
+
+// observeLeadTime adds the lead time, in minutes, of a successful run listed
+// from the window starting at since to the distributions enabled by
+// LEAD_TIME_DISTRIBUTION, unless it was observed before. Runs of the __all__
+// aggregate, queried with an empty branch, are already observed in the series
+// of their branch.
+func observeLeadTime(repoFullName string, branch string, run pipelineRun, since time.Time, minutes float64) {
+	if (leadTimeHistogram == nil && leadTimeSummary == nil) || branch == "" {
+		return
+	}
+	id := fmt.Sprintf("%s|%d|%d", run.HeadSHA, run.CreatedAt.UnixNano(), run.CompletedAt.UnixNano())
+	if !leadTimeObservations.observe(seriesKey{Repo: repoFullName, Branch: branch}, id, run.CompletedAt, since) {
+		return
+	}
+
+	if leadTimeHistogram != nil {
+		leadTimeHistogram.WithLabelValues(branch, repoFullName).Observe(minutes)
+	}
+	if leadTimeSummary != nil {
+		leadTimeSummary.WithLabelValues(branch, repoFullName).Observe(minutes)
+	}
+}
+
+// leadTimeStart returns when the lead time of a successful run starts.
+// previous is the successful run completed before it, or nil if there is none
+// in the window.
+func leadTimeStart(provider Provider, repoFullName string, run pipelineRun, previous *pipelineRun) (time.Time, error) {
+	switch cfg.LeadTimeMode {
+	case leadTimeModeHeadCommit:
+		if !run.HeadCommitAt.IsZero() {
+			return run.HeadCommitAt, nil
+		}
+		return oldestCommitTime(provider, repoFullName, "", run)
+	case leadTimeModeOldestCommit:
+		// Without a previous deployment the range of shipped commits is
+		// unknown, so fall back to the head commit.
+		base := ""
+		if previous != nil && previous.HeadSHA != run.HeadSHA {
+			base = previous.HeadSHA
+		}
+		if base == "" && !run.HeadCommitAt.IsZero() {
+			return run.HeadCommitAt, nil
+		}
+		return oldestCommitTime(provider, repoFullName, base, run)
+	case leadTimeModeDeployInterval:
+		// Only the runs already listed are used, so the first run in the
+		// window falls back to its own duration.
+		if previous == nil {
+			return run.CreatedAt, nil
+		}
+		if !previous.HeadCommitAt.IsZero() {
+			return previous.HeadCommitAt, nil
+		}
+		return previous.CompletedAt, nil
+	default:
+		return run.CreatedAt, nil
+	}
+}
+
+// maxOldestCommitCacheEntries bounds the memory used by oldestCommitCache.
+const maxOldestCommitCacheEntries = 10000
+
+// oldestCommitCache holds the oldest commit time of the commits between two
+// SHAs, by repo, base and head. Commits never change, so entries stay valid;
+// the cache is only emptied once it grows too large.
+var oldestCommitCache = struct {
+	mu    sync.Mutex
+	times map[string]time.Time
+}{times: make(map[string]time.Time)}
+
+// oldestCommitTime returns the time of the oldest commit reachable from the
+// run's head but not from base, caching it in oldestCommitCache.
+func oldestCommitTime(provider Provider, repoFullName string, base string, run pipelineRun) (time.Time, error) {
+	key := repoFullName + "@" + base + ".." + run.HeadSHA
+	oldestCommitCache.mu.Lock()
+	oldest, ok := oldestCommitCache.times[key]
+	oldestCommitCache.mu.Unlock()
+	if ok {
+		return oldest, nil
+	}
+
+	times, err := provider.ListCommitTimes(repoFullName, base, run.HeadSHA)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if len(times) == 0 {
+		return time.Time{}, fmt.Errorf("no commits found for %s", run.HeadSHA)
+	}
+
+	oldest = times[0]
+	for _, t := range times[1:] {
+		if t.Before(oldest) {
+			oldest = t
+		}
+	}
+
+	oldestCommitCache.mu.Lock()
+	if len(oldestCommitCache.times) >= maxOldestCommitCacheEntries {
+		oldestCommitCache.times = make(map[string]time.Time)
+	}
+	oldestCommitCache.times[key] = oldest
+	oldestCommitCache.mu.Unlock()
+	return oldest, nil
+}
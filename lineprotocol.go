@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const lineProtocolMeasurement = "dora"
+
+// lineProtocolTagEscaper escapes the characters InfluxDB line protocol gives
+// a meaning to in tag values.
+var lineProtocolTagEscaper = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+
+// lineProtocolSink writes each result as one line of InfluxDB line protocol,
+// e.g. for Telegraf to read from the app's stdout.
+type lineProtocolSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *lineProtocolSink) Publish(metrics *DoraMetrics) error {
+	line := formatLineProtocol(metrics)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := io.WriteString(s.w, line)
+	return err
+}
+
+// formatLineProtocol returns metrics as a "dora" point tagged with the repo
+// and branch, timestamped in nanoseconds. Headline metrics that failed, are
+// disabled or had too few samples are left out rather than written as zero.
+func formatLineProtocol(metrics *DoraMetrics) string {
+	var fields []string
+	addFloat := func(name string, value float64) {
+		fields = append(fields, name+"="+strconv.FormatFloat(value, 'f', -1, 64))
+	}
+	addInt := func(name string, value int) {
+		fields = append(fields, name+"="+strconv.Itoa(value)+"i")
+	}
+	published := func(metric string) bool {
+		_, failed := metrics.Errors[metric]
+		return !failed && metricEnabled(metric) && !slices.Contains(metrics.InsufficientSamples, metric)
+	}
+
+	if published(metricDeploymentFrequency) {
+		addFloat(metricDeploymentFrequency, metrics.DeploymentFrequency)
+		addInt("successful_deployments", metrics.SuccessfulDeployments)
+		addInt("failed_deployments", metrics.FailedDeployments)
+		addFloat(metricDeployRecoveryTime, metrics.DeployRecoveryTime)
+	}
+	if published(metricLeadTimeForChanges) {
+		addFloat(metricLeadTimeForChanges, metrics.LeadTimeForChanges)
+		addInt("lead_time_sample_count", metrics.LeadTimeSampleCount)
+	}
+	if published(metricTimeToRestoreService) && restoreTimeEnabled(metrics.Repo) {
+		addFloat(metricTimeToRestoreService, metrics.TimeToRestoreService)
+		addInt("incident_count", metrics.IncidentCount)
+	}
+	if published(metricChangeFailureRate) {
+		addFloat(metricChangeFailureRate, metrics.ChangeFailureRate)
+		addInt("deployment_attempts", metrics.DeploymentAttempts)
+	}
+	addFloat("composite_score", metrics.CompositeScore)
+
+	return fmt.Sprintf("%s,branch=%s,repo=%s %s %d\n",
+		lineProtocolMeasurement,
+		lineProtocolTagEscaper.Replace(metrics.Branch),
+		lineProtocolTagEscaper.Replace(metrics.Repo),
+		strings.Join(fields, ","),
+		metrics.ComputedAt.UnixNano(),
+	)
+}
@@ -3,10 +3,16 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
 	"io"
 	"log"
+	"math"
 	"net/http"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -17,127 +23,474 @@ import (
 	"golang.org/x/oauth2"
 )
 
+// defaultMaxWebhookBodyBytes comfortably covers legitimate GitHub payloads.
+const defaultMaxWebhookBodyBytes = 5 << 20
+
+// DoraMetrics is the result of Calculator.Compute. Its JSON encoding is
+// part of the API, so fields are only ever added.
 type DoraMetrics struct {
-	DeploymentFrequency   float64
-	LeadTimeForChanges    float64
-	TimeToRestoreService  float64
-	ChangeFailureRate     float64
-	SuccessfulDeployments int
-	FailedDeployments     int
-	Branch                string
+	DeploymentFrequency      float64            `json:"deployment_frequency"`
+	DeploymentFrequencyDays  float64            `json:"deployment_frequency_days"`
+	LeadTimeForChanges       float64            `json:"lead_time_for_changes"`
+	LeadTimeForNormalChanges float64            `json:"lead_time_for_normal_changes"`
+	LeadTimeForHotfixes      float64            `json:"lead_time_for_hotfixes"`
+	LeadTimeSampleCount      int                `json:"lead_time_sample_count"`
+	HotfixCount              int                `json:"hotfix_count"`
+	TimeToRestoreService     float64            `json:"time_to_restore_service"`
+	TimeToRestoreBySeverity  map[string]float64 `json:"time_to_restore_by_severity,omitempty"`
+	IncidentCount            int                `json:"incident_count"`
+	// OpenIncidents and OpenIncidentAgeSeconds, the age of the oldest open
+	// incident, are only calculated when INCLUDE_OPEN_INCIDENTS is set.
+	OpenIncidents              int                                `json:"open_incidents,omitempty"`
+	OpenIncidentAgeSeconds     float64                            `json:"open_incident_age_seconds,omitempty"`
+	ChangeFailureRate          float64                            `json:"change_failure_rate"`
+	ChangeFailures             int                                `json:"change_failures"`
+	DeploymentAttempts         int                                `json:"deployment_attempts"`
+	SuccessfulDeployments      int                                `json:"successful_deployments"`
+	FailedDeployments          int                                `json:"failed_deployments"`
+	SecondsSinceLastDeployment float64                            `json:"seconds_since_last_deployment"`
+	Environments               map[string]*EnvironmentDeployments `json:"environments,omitempty"`
+	DailyDeployments           []DailyCount                       `json:"daily_deployments,omitempty"`
+	// DeployRecoveryTime is the average time, in minutes, from a failed
+	// deployment to the next successful one, over DeployRecoveries
+	// recovered failures.
+	DeployRecoveryTime float64 `json:"deploy_recovery_time"`
+	DeployRecoveries   int     `json:"deploy_recoveries"`
+	// DeploymentFrequencyCompleteDays reports that DeploymentFrequency was
+	// calculated over complete days only, as set by FREQUENCY_COMPLETE_DAYS.
+	DeploymentFrequencyCompleteDays bool `json:"deployment_frequency_complete_days"`
+	// WeightedDeploymentFrequency is the deployment frequency with every
+	// deployment weighted by its age, halving every FREQUENCY_HALF_LIFE. It
+	// is only calculated when FREQUENCY_HALF_LIFE is set.
+	WeightedDeploymentFrequency float64 `json:"weighted_deployment_frequency,omitempty"`
+	// DeploymentsByActor counts deployment attempts per triggering user or
+	// team. It is only calculated when DEPLOYMENTS_BY_ACTOR is set.
+	DeploymentsByActor map[string]int `json:"deployments_by_actor,omitempty"`
+	Repo               string         `json:"repo"`
+	Branch             string         `json:"branch"`
+	// CompositeScore rolls the four metrics up into a 0-100 score, see
+	// compositeScore.
+	CompositeScore float64 `json:"composite_score"`
+	// PullRequests is only calculated when PULL_REQUEST_METRICS is set.
+	PullRequests *PullRequestMetrics `json:"pull_requests,omitempty"`
+	// Units maps each headline metric to the unit it is reported in.
+	Units map[string]string `json:"units"`
+	// InsufficientSamples lists the headline metrics calculated from fewer
+	// samples than their *_MIN_SAMPLES setting. Their values are noise and
+	// are not published as gauges.
+	InsufficientSamples []string `json:"insufficient_samples,omitempty"`
+	// Errors maps a sub-metric name to the reason it could not be calculated.
+	// The corresponding values are zero and should not be trusted.
+	Errors map[string]string `json:"errors,omitempty"`
+	// Partial maps a sub-metric name to the error that cut short a listing
+	// it was calculated from. The value only covers what was fetched.
+	Partial map[string]string `json:"partial,omitempty"`
+	// ComputedAt is when the calculation started fetching from the provider,
+	// so changes made after it may be missing from the metrics.
+	ComputedAt time.Time `json:"computed_at"`
 }
 
-var (
-	deploymentFrequency = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "dora_deployment_frequency",
-		Help: "Deployment Frequency metric",
-	}, []string{"branch"})
-	leadTimeForChanges = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "dora_lead_time_for_changes_minutes",
-		Help: "Lead Time for Changes metric (in minutes)",
-	}, []string{"branch"})
-	timeToRestoreService = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "dora_time_to_restore_service",
-		Help: "Time to Restore Service metric",
-	}, []string{"branch"})
-	changeFailureRate = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "dora_change_failure_rate",
-		Help: "Change Failure Rate metric",
-	}, []string{"branch"})
-	successfulDeployments = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "dora_successful_deployments",
-		Help: "Number of successful deployments in the last 30 days",
-	}, []string{"branch"})
-	failedDeployments = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "dora_failed_deployments",
-		Help: "Number of failed deployments in the last 30 days",
-	}, []string{"branch"})
-)
+// EnvironmentDeployments breaks the deployment counts down by the environment
+// a deployment targeted.
+type EnvironmentDeployments struct {
+	DeploymentFrequency   float64 `json:"deployment_frequency"`
+	SuccessfulDeployments int     `json:"successful_deployments"`
+	FailedDeployments     int     `json:"failed_deployments"`
+	// LatestVersion is the version of the most recent successful deployment,
+	// read from DEPLOYMENT_MANIFEST_ARTIFACT. Empty if unknown.
+	LatestVersion string `json:"latest_version,omitempty"`
+	latestAt      time.Time
+}
 
-func init() {
-	prometheus.MustRegister(deploymentFrequency)
-	prometheus.MustRegister(leadTimeForChanges)
-	prometheus.MustRegister(timeToRestoreService)
-	prometheus.MustRegister(changeFailureRate)
-	prometheus.MustRegister(successfulDeployments)
-	prometheus.MustRegister(failedDeployments)
+type deploymentStats struct {
+	Frequency                  float64
+	WeightedFrequency          float64
+	WindowDays                 float64
+	Successful                 int
+	Failed                     int
+	SecondsSinceLastDeployment float64
+	RecoveryMinutes            float64
+	Recoveries                 int
+	Environments               map[string]*EnvironmentDeployments
+	Daily                      []DailyCount
+	ByActor                    map[string]int
 }
 
+// Sub-metric names used as keys in DoraMetrics.Errors and DoraMetrics.Units.
+// They match the JSON names of the corresponding fields.
+const (
+	metricDeploymentFrequency  = "deployment_frequency"
+	metricLeadTimeForChanges   = "lead_time_for_changes"
+	metricTimeToRestoreService = "time_to_restore_service"
+	metricChangeFailureRate    = "change_failure_rate"
+	metricDeployRecoveryTime   = "deploy_recovery_time"
+	metricPullRequests         = "pull_requests"
+	metricOpenIncidents        = "open_incidents"
+)
+
 func main() {
+	once := flag.Bool("once", false, "compute the metrics of -repo and -branch, print them as JSON and exit")
+	onceRepo := flag.String("repo", "", "repository to compute metrics for with -once or -backfill, as owner/repo")
+	onceBranch := flag.String("branch", "", "branch to compute metrics for with -once or -backfill")
+	backfill := flag.Bool("backfill", false, "compute the metrics of -repo and -branch as of the end of each day from -from to -to, write them as JSON lines to -out and exit")
+	backfillFrom := flag.String("from", "", "first day to compute metrics for with -backfill, as YYYY-MM-DD")
+	backfillTo := flag.String("to", "", "last day to compute metrics for with -backfill, as YYYY-MM-DD")
+	backfillOut := flag.String("out", "", "file to write the -backfill snapshots to instead of stdout")
+	flag.Parse()
+	oneShot := *once || *backfill
+
 	err := godotenv.Load()
 	if err != nil {
 		log.Println("scanning .env file for environment variables")
 	}
 
-	token := os.Getenv("GITHUB_TOKEN")
-	webhookSecret := os.Getenv("WEBHOOK_SECRET")
+	if err := loadConfig(); err != nil {
+		log.Fatal(err)
+	}
+	registerMetrics()
+	sinks = newMetricsSinks()
+	deliveries = newDeliveryCache(cfg.DeliveryCacheSize, cfg.DeliveryCacheTTL)
 
-	if token == "" || webhookSecret == "" {
-		log.Fatal("GITHUB_TOKEN and WEBHOOK_SECRET must be set")
+	token, err := getenvOrFile("GITHUB_TOKEN")
+	if err != nil {
+		log.Fatal(err)
+	}
+	githubTokens := parseGitHubTokens(os.Getenv("GITHUB_TOKENS"), token)
+	gitlabToken, err := getenvOrFile("GITLAB_TOKEN")
+	if err != nil {
+		log.Fatal(err)
 	}
+	webhookSecret, err := getenvOrFile("WEBHOOK_SECRET")
+	if err != nil {
+		log.Fatal(err)
+	}
+	webhookSecrets := parseWebhookSecrets(os.Getenv("WEBHOOK_SECRETS"), webhookSecret)
 
-	ctx := context.Background()
-	ts := oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: token},
-	)
-	tc := oauth2.NewClient(ctx, ts)
+	var backfillStart, backfillEnd time.Time
+	if *backfill {
+		backfillStart, backfillEnd, err = parseBackfillRange(*backfillFrom, *backfillTo)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
 
-	client := github.NewClient(tc)
+	switch {
+	case *once && *backfill:
+		log.Fatal("-once and -backfill cannot be used together")
+	case oneShot && cfg.SCMProvider == scmProviderGitLab && gitlabToken == "":
+		log.Fatal("GITLAB_TOKEN must be set")
+	case oneShot && cfg.SCMProvider == scmProviderGitHub && len(githubTokens) == 0:
+		log.Fatal("GITHUB_TOKEN (or GITHUB_TOKENS) must be set")
+	case oneShot && (*onceRepo == "" || *onceBranch == ""):
+		log.Fatal("-repo and -branch must be set with -once or -backfill")
+	case oneShot:
+	case cfg.SCMProvider == scmProviderGitLab && (gitlabToken == "" || len(webhookSecrets) == 0):
+		log.Fatal("GITLAB_TOKEN and WEBHOOK_SECRET (or WEBHOOK_SECRETS) must be set")
+	case cfg.SCMProvider == scmProviderGitHub && (len(githubTokens) == 0 || len(webhookSecrets) == 0):
+		log.Fatal("GITHUB_TOKEN (or GITHUB_TOKENS) and WEBHOOK_SECRET (or WEBHOOK_SECRETS) must be set")
+	}
 
-	http.HandleFunc("/webhook", func(w http.ResponseWriter, r *http.Request) {
-		payload, err := io.ReadAll(r.Body)
-		if err != nil {
-			log.Printf("Error reading request body: %v", err)
-			http.Error(w, "Error reading request body", http.StatusBadRequest)
-			return
+	maxBodyBytes := int64(defaultMaxWebhookBodyBytes)
+	if v := os.Getenv("WEBHOOK_MAX_BODY_BYTES"); v != "" {
+		maxBodyBytes, err = strconv.ParseInt(v, 10, 64)
+		if err != nil || maxBodyBytes <= 0 {
+			log.Fatalf("invalid WEBHOOK_MAX_BODY_BYTES %q", v)
 		}
-		defer r.Body.Close()
+	}
 
-		if err := github.ValidateSignature(r.Header.Get("X-Hub-Signature"), payload, []byte(webhookSecret)); err != nil {
-			log.Printf("Error validating payload: %v", err)
-			http.Error(w, "Invalid payload", http.StatusBadRequest)
-			return
+	batchConcurrency := defaultBatchConcurrency
+	if v := os.Getenv("BATCH_CONCURRENCY"); v != "" {
+		batchConcurrency, err = strconv.Atoi(v)
+		if err != nil || batchConcurrency <= 0 {
+			log.Fatalf("invalid BATCH_CONCURRENCY %q", v)
 		}
+	}
 
-		event, err := github.ParseWebHook(github.WebHookType(r), payload)
-		if err != nil {
-			log.Printf("Error parsing webhook: %v", err)
-			http.Error(w, "Error parsing webhook", http.StatusBadRequest)
-			return
+	var refreshInterval time.Duration
+	if v := os.Getenv("REFRESH_INTERVAL"); v != "" {
+		refreshInterval, err = time.ParseDuration(v)
+		if err != nil || refreshInterval <= 0 {
+			log.Fatalf("invalid REFRESH_INTERVAL %q", v)
 		}
+	}
+	if cfg.Org != "" && refreshInterval == 0 && !oneShot {
+		log.Fatal("REFRESH_INTERVAL must be set when ORG is set")
+	}
 
-		switch e := event.(type) {
-		case *github.PushEvent:
-			log.Printf("Received PushEvent for %s on branch %s", e.Repo.GetFullName(), e.GetRef())
-			handleMetricsUpdate(client, e.Repo.GetFullName(), getBranchFromRef(e.GetRef()), w)
-		case *github.WorkflowRunEvent:
-			log.Printf("Received WorkflowRunEvent for %s on branch %s", e.Repo.GetFullName(), e.WorkflowRun.GetHeadBranch())
-			handleMetricsUpdate(client, e.Repo.GetFullName(), e.WorkflowRun.GetHeadBranch(), w)
-		case *github.PingEvent:
-			w.Write([]byte("Pong!"))
-		case *github.CheckRunEvent:
-			log.Printf("Received CheckRunEvent for %s on branch %s", e.Repo.GetFullName(), e.CheckRun.GetCheckSuite().GetHeadBranch())
-		case *github.CheckSuiteEvent:
-			log.Printf("Received CheckSuiteEvent for %s on branch %s", e.Repo.GetFullName(), e.CheckSuite.GetHeadBranch())
-		default:
-			log.Printf("Received unhandled event type: %s", github.WebHookType(r))
+	transport, err := newGitHubTransport(os.Getenv("GITHUB_CA_BUNDLE"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	var githubTransport http.RoundTripper = transport
+	if v := os.Getenv("GITHUB_REQUESTS_PER_HOUR"); v != "" {
+		perHour, err := strconv.Atoi(v)
+		if err != nil || perHour <= 0 {
+			log.Fatalf("invalid GITHUB_REQUESTS_PER_HOUR %q", v)
 		}
-	})
+		limiter := newTokenBucket(perHour)
+		githubTransport = &rateLimitedTransport{next: transport, limiter: limiter}
+		prometheus.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: metricName("github_request_budget"),
+			Help: "Number of GitHub API requests that can be made before the rate limiter starts waiting",
+		}, limiter.remaining))
+	}
+	if cfg.GitHubRateLimitMetrics {
+		githubTransport = &rateLimitObserver{next: githubTransport}
+	}
+	var tc *http.Client
+	// tokenClients each use one token, to check them at startup.
+	var tokenClients []*github.Client
+	if len(githubTokens) > 1 {
+		pool := newTokenPoolTransport(githubTokens, githubTransport)
+		for i, t := range githubTokens {
+			tokenClients = append(tokenClients, github.NewClient(&http.Client{Transport: newTokenPoolTransport([]string{t}, githubTransport)}))
+			prometheus.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+				Name:        metricName("github_token_rate_limit_remaining"),
+				Help:        "Number of GitHub API requests left for each of GITHUB_TOKENS, as last reported by GitHub",
+				ConstLabels: prometheus.Labels{"token": strconv.Itoa(i + 1)},
+			}, func() float64 { return pool.remaining(i) }))
+		}
+		tc = &http.Client{Transport: pool}
+	} else {
+		// oauth2 wraps the client found in the context with the token source.
+		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{Transport: githubTransport})
+		var accessToken string
+		if len(githubTokens) > 0 {
+			accessToken = githubTokens[0]
+		}
+		ts := oauth2.StaticTokenSource(
+			&oauth2.Token{AccessToken: accessToken},
+		)
+		tc = oauth2.NewClient(ctx, ts)
+	}
+
+	client := github.NewClient(tc)
+	if tokenClients == nil {
+		tokenClients = []*github.Client{client}
+	}
+
+	notifier, err := newSlackNotifierFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	http.Handle("/metrics", promhttp.Handler())
+	var provider Provider
+	// discover lists the repo/branches of ORG to refresh.
+	var discover func() ([]seriesKey, error)
+	switch cfg.SCMProvider {
+	case scmProviderGitLab:
+		provider = newGitLabProvider(cfg.GitLabURL, gitlabToken)
+	default:
+		for _, tokenClient := range tokenClients {
+			if err := checkGitHubToken(tokenClient); err != nil {
+				log.Fatal(err)
+			}
+		}
+		githubProvider := newGitHubProvider(client, transport)
+		if cfg.Org != "" {
+			discover = func() ([]seriesKey, error) { return githubProvider.orgRepositories(cfg.Org) }
+		}
+		provider = githubProvider
+	}
+	calculator := newCalculator(provider)
+	pagerDutyToken, err := getenvOrFile("PAGERDUTY_TOKEN")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if pagerDutyToken != "" {
+		calculator.pagerDuty = newPagerDutyClient(cfg.PagerDutyURL, pagerDutyToken)
+	} else if pagerDutyRestoreTimeSource() {
+		log.Fatal("PAGERDUTY_TOKEN must be set when a restore time source is pagerduty")
+	}
+
+	if *once {
+		os.Exit(runOnce(calculator, *onceRepo, *onceBranch, os.Stdout))
+	}
+	if *backfill {
+		out := os.Stdout
+		if *backfillOut != "" {
+			out, err = os.Create(*backfillOut)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+		exitCode := runBackfill(calculator, *onceRepo, *onceBranch, backfillStart, backfillEnd, out)
+		if err := out.Close(); err != nil {
+			log.Fatal(err)
+		}
+		os.Exit(exitCode)
+	}
+
+	if cfg.AsyncWebhooks {
+		webhookQueue = newRecomputeQueue(calculator, notifier, cfg.WebhookQueueSize, cfg.WebhookQueueWorkers)
+		prometheus.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: metricName("webhook_queue_length"),
+			Help: "Number of repo/branches waiting to be recomputed after a webhook",
+		}, webhookQueue.length))
+	}
+
+	var webhookHandler http.HandlerFunc
+	switch cfg.SCMProvider {
+	case scmProviderGitLab:
+		webhookHandler = newGitLabWebhookHandler(calculator, webhookSecrets, maxBodyBytes, notifier)
+	default:
+		webhookHandler = newGitHubWebhookHandler(calculator, webhookSecrets, maxBodyBytes, notifier)
+	}
+	// Importing net/http/pprof registers its handlers on the default mux,
+	// so the app serves its own to keep them off the public listener.
+	mux := http.NewServeMux()
+	auditLog, err := openAuditLog(cfg.WebhookAuditLog)
+	if err != nil {
+		log.Fatal(err)
+	}
+	mux.HandleFunc("/webhook", withAuditLog(withIPAllowlist(webhookHandler, cfg.WebhookIPAllowlist, cfg.WebhookTrustedProxies), auditLog))
+
+	if refreshInterval > 0 {
+		go runRefreshLoop(calculator, refreshInterval, notifier, discover)
+	}
+
+	var apiAuth apiCredentials
+	if apiAuth.token, err = getenvOrFile("API_AUTH_TOKEN"); err != nil {
+		log.Fatal(err)
+	}
+	apiAuth.username = os.Getenv("API_AUTH_USERNAME")
+	if apiAuth.password, err = getenvOrFile("API_AUTH_PASSWORD"); err != nil {
+		log.Fatal(err)
+	}
+	if (apiAuth.username == "") != (apiAuth.password == "") {
+		log.Fatal("API_AUTH_USERNAME and API_AUTH_PASSWORD must be set together")
+	}
+	// The webhook is authenticated by its signature and the admin endpoints
+	// by ADMIN_TOKEN; every other endpoint requires the API credentials.
+	metricsHandler := promhttp.Handler()
+	if cfg.DeploymentExemplars {
+		// Exemplars are only exposed in the OpenMetrics format.
+		metricsHandler = promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer,
+			promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{EnableOpenMetrics: true}))
+	}
+	mux.Handle("/metrics", withAPIAuth(metricsHandler, apiAuth))
+	mux.Handle("/metrics/dora/batch", withAPIAuth(newBatchHandler(calculator, batchConcurrency), apiAuth))
+	mux.Handle("/branches", withAPIAuth(http.HandlerFunc(handleBranches), apiAuth))
+	mux.Handle("/summary", withAPIAuth(http.HandlerFunc(handleSummary), apiAuth))
+	mux.Handle("/metrics/repo", withAPIAuth(http.HandlerFunc(handleRepoMetrics), apiAuth))
+	adminToken, err := getenvOrFile("ADMIN_TOKEN")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if adminToken != "" {
+		mux.HandleFunc("/metrics/series", newDeleteSeriesHandler(adminToken))
+		mux.HandleFunc("/recompute", newRecomputeHandler(calculator, adminToken, notifier))
+	}
+	mux.Handle("/config", withAPIAuth(newConfigHandler(map[string]bool{
+		"GITHUB_TOKEN":      len(githubTokens) > 0,
+		"GITLAB_TOKEN":      gitlabToken != "",
+		"WEBHOOK_SECRET":    len(webhookSecrets) > 0,
+		"SLACK_WEBHOOK_URL": os.Getenv("SLACK_WEBHOOK_URL") != "",
+		"ADMIN_TOKEN":       adminToken != "",
+		"API_AUTH_TOKEN":    apiAuth.token != "",
+		"API_AUTH_PASSWORD": apiAuth.password != "",
+		"PAGERDUTY_TOKEN":   pagerDutyToken != "",
+	}), apiAuth))
+
+	if cfg.GRPCAddr != "" {
+		broadcaster := newMetricsBroadcaster()
+		sinks = append(sinks, broadcaster)
+		go serveGRPC(cfg.GRPCAddr, calculator, notifier, broadcaster, apiAuth)
+	}
+	if cfg.EnablePprof {
+		go servePprof(cfg.PprofAddr)
+	}
 
 	log.Println("Server is running on :4040")
-	log.Fatal(http.ListenAndServe(":4040", nil))
+	log.Fatal(http.ListenAndServe(":4040", mux))
 }
 
-func handleMetricsUpdate(client *github.Client, repoFullName string, branch string, w http.ResponseWriter) {
-	metrics, err := calculateDoraMetrics(client, repoFullName, branch)
+// readWebhookBody reads the request body, rejecting bodies larger than
+// maxBodyBytes. It writes the error response itself and returns false when the
+// body could not be read.
+func readWebhookBody(w http.ResponseWriter, r *http.Request, maxBodyBytes int64) ([]byte, bool) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+	payload, err := io.ReadAll(r.Body)
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		log.Printf("Request body exceeds %d bytes", maxBytesErr.Limit)
+		http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+		return nil, false
+	}
+	if err != nil {
+		log.Printf("Error reading request body: %v", err)
+		http.Error(w, "Error reading request body", http.StatusBadRequest)
+		return nil, false
+	}
+	defer r.Body.Close()
+
+	return payload, true
+}
+
+func handleMetricsUpdate(calculator *Calculator, repoFullName string, branch string, notifier *slackNotifier, w http.ResponseWriter) {
+	// Scheduled runs and runs from forks can arrive without a head branch;
+	// recording them would create series with an empty branch label.
+	if branch == "" {
+		log.Printf("Skipping metrics for %s: event has no branch", repoFullName)
+		w.Write([]byte("Skipped event without a branch"))
+		return
+	}
+
+	if cfg.ProductionBranchOnly {
+		owner, repo, err := parseRepoFullName(repoFullName)
+		if err != nil {
+			log.Printf("Error calculating DORA metrics: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		production, err := productionBranch(calculator.provider, owner+"/"+repo)
+		if err != nil {
+			log.Printf("Error determining production branch: %v", err)
+			http.Error(w, "Error determining production branch", http.StatusInternalServerError)
+			return
+		}
+		if branch != production {
+			log.Printf("Skipping metrics for %s on non-production branch %s", repoFullName, branch)
+			w.Write([]byte("Skipped non-production branch"))
+			return
+		}
+	}
+
+	if webhookQueue != nil {
+		owner, repo, err := parseRepoFullName(repoFullName)
+		if err != nil {
+			log.Printf("Error calculating DORA metrics: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !webhookQueue.enqueue(seriesKey{Repo: owner + "/" + repo, Branch: branch}) {
+			log.Printf("Dropping recompute of %s on branch %s: queue is full", repoFullName, branch)
+			http.Error(w, "Recompute queue is full", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("Queued recompute"))
+		return
+	}
+
+	// Webhook recalculations run to completion even if the sender stops
+	// waiting for the response.
+	metrics, err := recomputeMetrics(context.Background(), calculator, repoFullName, branch, notifier)
+	if errors.Is(err, errInvalidRepoFullName) {
+		log.Printf("Error calculating DORA metrics: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		log.Printf("Error calculating DORA metrics: %v", err)
 		http.Error(w, "Error calculating DORA metrics", http.StatusInternalServerError)
 		return
 	}
-	updatePrometheusMetrics(metrics)
+	if cfg.AggregateBranches {
+		if _, err := recomputeMetrics(context.Background(), calculator, metrics.Repo, allBranches, notifier); err != nil {
+			log.Printf("Error calculating aggregate DORA metrics for %s: %v", metrics.Repo, err)
+		}
+	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	if err := json.NewEncoder(w).Encode(metrics); err != nil {
@@ -145,171 +498,519 @@ func handleMetricsUpdate(client *github.Client, repoFullName string, branch stri
 	}
 }
 
-func calculateDoraMetrics(client *github.Client, repoFullName string, branch string) (*DoraMetrics, error) {
+// recomputeMetrics calculates and publishes the metrics for a repo/branch.
+// Recalculations of the same repo/branch run one at a time, so the gauges
+// always end up holding the result of the most recently started one.
+func recomputeMetrics(ctx context.Context, calculator *Calculator, repoFullName string, branch string, notifier *slackNotifier) (*DoraMetrics, error) {
+	owner, repo, err := parseRepoFullName(repoFullName)
+	if err != nil {
+		return nil, err
+	}
+	key := seriesKey{Repo: owner + "/" + repo, Branch: branch}
+
+	unlock := recomputeLocks.lock(key)
+	defer unlock()
+
+	// Bursts of webhooks for the same repo/branch are served from the last
+	// result instead of querying the provider again.
+	if entry, ok := seenKeys.get(key); ok && calculator.now().Sub(entry.ComputedAt) < cfg.MinRecomputeInterval {
+		log.Printf("Using DORA metrics for %s on branch %s computed %s ago", key.Repo, key.Branch, calculator.now().Sub(entry.ComputedAt).Round(time.Second))
+		return entry.Metrics, nil
+	}
+
+	metrics, err := calculator.Compute(ctx, key.Repo, key.Branch)
+	if err != nil {
+		return nil, err
+	}
+	seenKeys.put(key, metrics)
+	publishMetrics(metrics)
+	notifier.notifyIfNeeded(key.Repo, calculator.provider.RepositoryURL(key.Repo), metrics)
+	recomputeServiceMetrics(ctx, calculator, key)
+	return metrics, nil
+}
+
+// Compute calculates the DORA metrics of repo/branch. The provider requests
+// are not cancelled, so ctx is only checked before the calculation starts,
+// e.g. to skip the work of a client that has gone away.
+func (c *Calculator) Compute(ctx context.Context, repoFullName string, branch string) (*DoraMetrics, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	startedAt := c.now()
+	owner, repo, err := parseRepoFullName(repoFullName)
+	if err != nil {
+		return nil, err
+	}
+	repoFullName = owner + "/" + repo
+
 	log.Printf("Calculating DORA metrics for %s on branch %s", repoFullName, branch)
 
-	deploymentFreq, successfulDeps, failedDeps := calculateDeploymentFrequency(client, repoFullName, branch)
-	leadTime := calculateLeadTimeForChanges(client, repoFullName, branch)
-	restoreTime := calculateTimeToRestoreService(client, repoFullName, branch)
-	failureRate := calculateChangeFailureRate(client, repoFullName, branch)
+	// The aggregate series reads the deployments and runs of every branch.
+	queryBranch := branch
+	if branch == allBranches {
+		queryBranch = ""
+	}
+
+	errs := make(map[string]string)
+	// partial holds the metrics calculated from an incomplete listing, which
+	// are still published.
+	partial := make(map[string]string)
+	recordErr := func(metric string, err error) {
+		if isPartialList(err) {
+			log.Printf("Calculated %s from incomplete data: %v", metric, err)
+			partial[metric] = err.Error()
+			return
+		}
+		if err != nil {
+			log.Printf("Error calculating %s: %v", metric, err)
+			errs[metric] = err.Error()
+		}
+	}
+
+	// Metrics left out of ENABLED_METRICS are not calculated and stay zero.
+	var deployStats *deploymentStats
+	if metricEnabled(metricDeploymentFrequency) {
+		deployStats, err = c.deploymentFrequency(repoFullName, queryBranch)
+		recordErr(metricDeploymentFrequency, err)
+	}
+	if deployStats == nil {
+		deployStats = &deploymentStats{}
+	}
+	var leadTime *leadTimeStats
+	if metricEnabled(metricLeadTimeForChanges) {
+		leadTime, err = c.leadTimeForChanges(repoFullName, queryBranch)
+		recordErr(metricLeadTimeForChanges, err)
+	}
+	if leadTime == nil {
+		leadTime = &leadTimeStats{}
+	}
+	var restoreTime *restoreStats
+	if restoreTimeEnabled(repoFullName) {
+		restoreTime, err = c.timeToRestoreService(repoFullName, queryBranch)
+		recordErr(metricTimeToRestoreService, err)
+	}
+	if restoreTime == nil {
+		restoreTime = &restoreStats{}
+	}
+	var failureRate float64
+	var changeFailures, deploymentAttempts int
+	if metricEnabled(metricChangeFailureRate) {
+		failureRate, changeFailures, deploymentAttempts, err = c.changeFailureRate(repoFullName, queryBranch)
+		recordErr(metricChangeFailureRate, err)
+	}
 
 	metrics := &DoraMetrics{
-		DeploymentFrequency:   deploymentFreq,
-		LeadTimeForChanges:    leadTime,
-		TimeToRestoreService:  restoreTime,
-		ChangeFailureRate:     failureRate,
-		SuccessfulDeployments: successfulDeps,
-		FailedDeployments:     failedDeps,
-		Branch:                branch,
+		DeploymentFrequency:        deployStats.Frequency,
+		DeploymentFrequencyDays:    deployStats.WindowDays,
+		LeadTimeForChanges:         leadTime.Minutes,
+		LeadTimeForNormalChanges:   leadTime.NormalMinutes,
+		LeadTimeForHotfixes:        leadTime.HotfixMinutes,
+		LeadTimeSampleCount:        leadTime.Samples,
+		HotfixCount:                leadTime.HotfixSamples,
+		TimeToRestoreService:       restoreTime.Hours,
+		TimeToRestoreBySeverity:    restoreTime.BySeverity,
+		IncidentCount:              restoreTime.Incidents,
+		ChangeFailureRate:          failureRate * changeFailureRateScale(),
+		ChangeFailures:             changeFailures,
+		DeploymentAttempts:         deploymentAttempts,
+		SuccessfulDeployments:      deployStats.Successful,
+		FailedDeployments:          deployStats.Failed,
+		SecondsSinceLastDeployment: deployStats.SecondsSinceLastDeployment,
+		DeployRecoveryTime:         deployStats.RecoveryMinutes,
+		DeployRecoveries:           deployStats.Recoveries,
+		Environments:               deployStats.Environments,
+		DailyDeployments:           deployStats.Daily,
+		DeploymentsByActor:         deployStats.ByActor,
+		Repo:                       repoFullName,
+		Branch:                     branch,
+		Units:                      metricUnits(),
+		ComputedAt:                 startedAt,
+	}
+	metrics.DeploymentFrequencyCompleteDays = cfg.FrequencyCompleteDays
+	metrics.WeightedDeploymentFrequency = deployStats.WeightedFrequency
+	if cfg.IncludeOpenIncidents {
+		age, count, err := c.openIncidents(repoFullName, queryBranch)
+		recordErr(metricOpenIncidents, err)
+		metrics.OpenIncidentAgeSeconds, metrics.OpenIncidents = age, count
+	}
+	if cfg.PullRequestMetrics {
+		pullRequests, err := c.pullRequestMetrics(repoFullName, queryBranch)
+		recordErr(metricPullRequests, err)
+		metrics.PullRequests = pullRequests
+	}
+	if len(errs) > 0 {
+		metrics.Errors = errs
 	}
+	if len(partial) > 0 {
+		metrics.Partial = partial
+	}
+	metrics.InsufficientSamples = insufficientSamples(metrics)
+	metrics.CompositeScore = compositeScore(metrics)
 
 	return metrics, nil
 }
 
-func calculateDeploymentFrequency(client *github.Client, repoFullName string, branch string) (float64, int, int) {
+// insufficientSamples returns the headline metrics of metrics calculated from
+// fewer samples than LEAD_TIME_MIN_SAMPLES, MTTR_MIN_SAMPLES or
+// CFR_MIN_SAMPLES require.
+func insufficientSamples(metrics *DoraMetrics) []string {
+	var insufficient []string
+	if metricEnabled(metricLeadTimeForChanges) && metrics.LeadTimeSampleCount < cfg.LeadTimeMinSamples {
+		insufficient = append(insufficient, metricLeadTimeForChanges)
+	}
+	if restoreTimeEnabled(metrics.Repo) && metrics.IncidentCount < cfg.RestoreTimeMinSamples {
+		insufficient = append(insufficient, metricTimeToRestoreService)
+	}
+	if metricEnabled(metricChangeFailureRate) && metrics.DeploymentAttempts < cfg.ChangeFailureRateMinSamples {
+		insufficient = append(insufficient, metricChangeFailureRate)
+	}
+	return insufficient
+}
+
+// deploymentFrequency returns the average deployments per day over
+// the DF_WINDOW_DAYS window, overall and per environment, together with the
+// successful and failed deployment counts, the seconds elapsed since the
+// most recent successful deployment and the deploy recovery time.
+func (c *Calculator) deploymentFrequency(repoFullName string, branch string) (*deploymentStats, error) {
 	log.Printf("Calculating Deployment Frequency for %s on branch %s", repoFullName, branch)
 
-	workflowRuns, _, err := client.Actions.ListRepositoryWorkflowRuns(context.Background(), getOwner(repoFullName), getRepo(repoFullName), &github.ListWorkflowRunsOptions{
-		Branch:      branch,
-		ListOptions: github.ListOptions{PerPage: 100},
-	})
+	now := c.now()
+	// windowEnd is when the counted deployments end: now, or the start of
+	// the current day with FREQUENCY_COMPLETE_DAYS.
+	windowEnd := now
+	if cfg.FrequencyCompleteDays {
+		windowEnd = now.UTC().Truncate(24 * time.Hour)
+	}
+	windowStart := windowEnd.AddDate(0, 0, -cfg.DeploymentFrequencyWindowDays)
+	attempts, listErr := c.provider.ListDeploymentAttempts(repoFullName, branch, windowStart)
+	if listErr != nil && !isPartialList(listErr) {
+		return nil, listErr
+	}
+	attempts, err := filterProductionAttempts(c.provider, repoFullName, attempts)
 	if err != nil {
-		log.Printf("Error fetching workflow runs: %v", err)
-		return 0, 0, 0
+		return nil, err
 	}
 
-	successfulDeployments := 0
-	failedDeployments := 0
-	thirtyDaysAgo := time.Now().AddDate(0, 0, -30)
-
-	for _, run := range workflowRuns.WorkflowRuns {
-		if run.GetCreatedAt().Time.After(thirtyDaysAgo) {
-			if run.GetConclusion() == "success" {
-				successfulDeployments++
-			} else {
-				failedDeployments++
+	observeDeployments(repoFullName, branch, attempts, windowStart)
+
+	stats := &deploymentStats{Environments: make(map[string]*EnvironmentDeployments)}
+	var lastSuccessfulDeployment time.Time
+	var counted []time.Time
+	for _, attempt := range attempts {
+		if attempt.Successful && attempt.CompletedAt.After(lastSuccessfulDeployment) {
+			lastSuccessfulDeployment = attempt.CompletedAt
+		}
+		at := deploymentTime(attempt.CreatedAt, attempt.CompletedAt)
+		if cfg.FrequencyCompleteDays && !at.Before(windowEnd) {
+			continue
+		}
+		counted = append(counted, at)
+		env, ok := stats.Environments[attempt.Environment]
+		if !ok {
+			env = &EnvironmentDeployments{}
+			stats.Environments[attempt.Environment] = env
+		}
+		if attempt.Successful {
+			stats.Successful++
+			env.SuccessfulDeployments++
+			if attempt.Version != "" && attempt.CompletedAt.After(env.latestAt) {
+				env.LatestVersion, env.latestAt = attempt.Version, attempt.CompletedAt
 			}
+		} else {
+			stats.Failed++
+			env.FailedDeployments++
 		}
 	}
 
-	frequency := float64(successfulDeployments+failedDeployments) / 30
-	log.Printf("Calculated Deployment Frequency: %f", frequency)
-	return frequency, successfulDeployments, failedDeployments
+	stats.Daily = dailyDeploymentCounts(attempts, windowStart, now)
+	stats.RecoveryMinutes, stats.Recoveries = deployRecoveryTime(attempts)
+	if cfg.DeploymentsByActor {
+		stats.ByActor = deploymentsByActor(attempts)
+	}
+
+	stats.SecondsSinceLastDeployment = now.Sub(windowStart).Seconds()
+	if !lastSuccessfulDeployment.IsZero() {
+		stats.SecondsSinceLastDeployment = now.Sub(lastSuccessfulDeployment).Seconds()
+	}
+
+	stats.WindowDays = float64(cfg.DeploymentFrequencyWindowDays)
+	if cfg.AdjustFrequencyForNewRepos {
+		repository, err := c.provider.GetRepository(repoFullName)
+		if err != nil {
+			return nil, err
+		}
+		// Repos younger than the window are averaged over their whole
+		// history, counted in started days so that the result stays finite.
+		if repository.CreatedAt.After(windowStart) {
+			stats.WindowDays = math.Max(1, math.Ceil(windowEnd.Sub(repository.CreatedAt).Hours()/24))
+		}
+	}
+
+	stats.Frequency = float64(stats.Successful+stats.Failed) / stats.WindowDays
+	if cfg.FrequencyHalfLife > 0 {
+		stats.WeightedFrequency = weightedDeploymentFrequency(counted, windowEnd, stats.WindowDays, cfg.FrequencyHalfLife)
+	}
+	for _, env := range stats.Environments {
+		env.DeploymentFrequency = float64(env.SuccessfulDeployments+env.FailedDeployments) / stats.WindowDays
+	}
+	log.Printf("Calculated Deployment Frequency: %f", stats.Frequency)
+	return stats, listErr
 }
 
-func calculateLeadTimeForChanges(client *github.Client, repoFullName string, branch string) float64 {
+// leadTimeStats is the result of leadTimeForChanges. All lead times
+// are averages in minutes.
+type leadTimeStats struct {
+	Minutes float64
+	// NormalMinutes and HotfixMinutes split Minutes by whether the run
+	// deployed a hotfix, as configured by the HOTFIX_* settings.
+	NormalMinutes float64
+	HotfixMinutes float64
+	Samples       int
+	HotfixSamples int
+}
+
+// leadTimeForChanges returns the average lead time, in minutes, of
+// the successful runs in the LEAD_TIME_WINDOW_DAYS window, overall and split
+// into hotfixes and normal changes. Where each lead time starts is set by
+// LEAD_TIME_MODE.
+func (c *Calculator) leadTimeForChanges(repoFullName string, branch string) (*leadTimeStats, error) {
 	log.Printf("Calculating Lead Time for Changes for %s on branch %s", repoFullName, branch)
 
-	workflowRuns, _, err := client.Actions.ListRepositoryWorkflowRuns(context.Background(), getOwner(repoFullName), getRepo(repoFullName), &github.ListWorkflowRunsOptions{
-		Status:      "success",
-		Branch:      branch,
-		ListOptions: github.ListOptions{PerPage: 100},
-	})
-	if err != nil {
-		log.Printf("Error fetching workflow runs: %v", err)
-		return 0
+	since := c.now().AddDate(0, 0, -cfg.LeadTimeWindowDays)
+	runs, listErr := c.provider.ListPipelineRuns(repoFullName, branch, since)
+	if listErr != nil && !isPartialList(listErr) {
+		return nil, listErr
+	}
+
+	var successfulRuns []pipelineRun
+	for _, run := range runs {
+		if classifyConclusion(run.Conclusion) != conclusionSuccess {
+			continue
+		}
+		touches, err := touchesProductionPaths(c.provider, repoFullName, run.HeadSHA)
+		if err != nil {
+			return nil, err
+		}
+		if touches {
+			successfulRuns = append(successfulRuns, run)
+		}
 	}
+	sort.Slice(successfulRuns, func(i, j int) bool {
+		return successfulRuns[i].CompletedAt.Before(successfulRuns[j].CompletedAt)
+	})
 
-	var totalLeadTime float64
-	var count int
-	for _, run := range workflowRuns.WorkflowRuns {
-		if run.CreatedAt != nil && run.UpdatedAt != nil && run.CreatedAt.After(time.Now().AddDate(0, 0, -30)) {
-			leadTime := run.UpdatedAt.Time.Sub(run.CreatedAt.Time).Minutes()
-			totalLeadTime += leadTime
-			count++
+	stats := &leadTimeStats{}
+	var totalLeadTime, totalHotfixLeadTime float64
+	for i, run := range successfulRuns {
+		var previous *pipelineRun
+		if i > 0 {
+			previous = &successfulRuns[i-1]
+		}
+		start, err := leadTimeStart(c.provider, repoFullName, run, previous)
+		if err != nil {
+			return nil, err
+		}
+		hotfix, err := isHotfix(c.provider, repoFullName, run)
+		if err != nil {
+			return nil, err
+		}
+		leadTime := run.CompletedAt.Sub(start).Minutes()
+		observeLeadTime(repoFullName, branch, run, since, leadTime)
+		totalLeadTime += leadTime
+		stats.Samples++
+		if hotfix {
+			totalHotfixLeadTime += leadTime
+			stats.HotfixSamples++
 		}
 	}
 
-	if count == 0 {
-		return 0
+	if stats.Samples == 0 {
+		return stats, listErr
+	}
+	stats.Minutes = totalLeadTime / float64(stats.Samples)
+	if stats.HotfixSamples > 0 {
+		stats.HotfixMinutes = totalHotfixLeadTime / float64(stats.HotfixSamples)
 	}
-	avgLeadTime := totalLeadTime / float64(count)
-	log.Printf("Calculated Lead Time for Changes: %.2f minutes", avgLeadTime)
-	return avgLeadTime
+	if normalSamples := stats.Samples - stats.HotfixSamples; normalSamples > 0 {
+		stats.NormalMinutes = (totalLeadTime - totalHotfixLeadTime) / float64(normalSamples)
+	}
+	log.Printf("Calculated Lead Time for Changes: %.2f minutes (%d hotfixes)", stats.Minutes, stats.HotfixSamples)
+	return stats, listErr
+}
+
+// restoreStats is the result of timeToRestoreService.
+type restoreStats struct {
+	// Hours is the mean restore time, weighted by severity when
+	// INCIDENT_SEVERITY_WEIGHTS is set.
+	Hours     float64
+	Incidents int
+	// BySeverity holds the unweighted mean restore time, in hours, of the
+	// incidents carrying each configured severity label.
+	BySeverity map[string]float64
 }
 
-func calculateTimeToRestoreService(client *github.Client, repoFullName string, branch string) float64 {
+// timeToRestoreService returns the average time to restore service,
+// in hours, and the number of incidents it was averaged over.
+func (c *Calculator) timeToRestoreService(repoFullName string, branch string) (*restoreStats, error) {
+	switch restoreTimeSource(repoFullName) {
+	case restoreTimeSourceDeployments:
+		return c.timeToRestoreFromDeployments(repoFullName, branch)
+	case restoreTimeSourceDeployRecovery:
+		return c.timeToRestoreFromDeployRecovery(repoFullName, branch)
+	case restoreTimeSourcePagerDuty:
+		return c.timeToRestoreFromPagerDuty(repoFullName, branch)
+	case restoreTimeSourceDisabled:
+		return &restoreStats{}, nil
+	}
+
 	log.Printf("Calculating Time to Restore Service for %s on branch %s", repoFullName, branch)
 
-	issues, _, err := client.Issues.ListByRepo(context.Background(), getOwner(repoFullName), getRepo(repoFullName), &github.IssueListByRepoOptions{
-		State:       "closed",
-		Labels:      []string{"incident"},
-		Since:       time.Now().AddDate(0, 0, -30),
-		ListOptions: github.ListOptions{PerPage: 100},
+	incidents, listErr := c.provider.ListIncidents(repoFullName, c.now().AddDate(0, 0, -cfg.RestoreTimeWindowDays))
+	if listErr != nil && !isPartialList(listErr) {
+		return nil, listErr
+	}
+
+	var branchIncidents []incident
+	for _, incident := range incidents {
+		// Check if the issue is related to the specified branch
+		if strings.Contains(incident.Body, branch) {
+			branchIncidents = append(branchIncidents, incident)
+		}
+	}
+	stats, err := incidentRestoreStats(branchIncidents, func(incident incident) (time.Time, error) {
+		return restoredAt(c.provider, repoFullName, incident)
 	})
 	if err != nil {
-		log.Printf("Error fetching issues: %v", err)
-		return 0
+		return nil, err
 	}
+	if stats.Incidents > 0 {
+		log.Printf("Calculated Time to Restore Service: %f hours over %d incidents", stats.Hours, stats.Incidents)
+	}
+	return stats, listErr
+}
 
-	totalRestoreTime := 0.0
-	incidentCount := 0
-	for _, issue := range issues {
-		// Check if the issue is related to the specified branch
-		if strings.Contains(issue.GetBody(), branch) {
-			restoreTime := issue.GetClosedAt().Sub(issue.GetCreatedAt()).Hours()
-			totalRestoreTime += restoreTime
-			incidentCount++
+// incidentRestoreStats averages the time from the creation of each incident
+// to resolvedAt, weighting it by severity.
+func incidentRestoreStats(incidents []incident, resolvedAt func(incident) (time.Time, error)) (*restoreStats, error) {
+	stats := &restoreStats{}
+	totalWeightedRestoreTime, totalWeight := 0.0, 0.0
+	severityRestoreTime := make(map[string]float64)
+	severityCount := make(map[string]int)
+	for _, incident := range incidents {
+		resolvedAt, err := resolvedAt(incident)
+		if err != nil {
+			return nil, err
+		}
+		restoreTime := restoreDuration(incident.CreatedAt, resolvedAt).Hours()
+		severity, weight := incidentSeverity(incident.Labels)
+		totalWeightedRestoreTime += restoreTime * weight
+		totalWeight += weight
+		stats.Incidents++
+		if severity != "" {
+			severityRestoreTime[severity] += restoreTime
+			severityCount[severity]++
 		}
 	}
 
-	if incidentCount == 0 {
-		return 0
+	if stats.Incidents == 0 {
+		return stats, nil
+	}
+	stats.Hours = totalWeightedRestoreTime / totalWeight
+	if len(severityCount) > 0 {
+		stats.BySeverity = make(map[string]float64, len(severityCount))
+		for severity, count := range severityCount {
+			stats.BySeverity[severity] = severityRestoreTime[severity] / float64(count)
+		}
 	}
-	avgRestoreTime := totalRestoreTime / float64(incidentCount)
-	log.Printf("Calculated Time to Restore Service: %f hours", avgRestoreTime)
-	return avgRestoreTime
+	return stats, nil
 }
 
-func calculateChangeFailureRate(client *github.Client, repoFullName string, branch string) float64 {
+// changeFailureRate returns the ratio of failed deployments to
+// deployment attempts, along with both raw counts. Attempts and failures come
+// from the same source as in deploymentFrequency.
+func (c *Calculator) changeFailureRate(repoFullName string, branch string) (float64, int, int, error) {
 	log.Printf("Calculating Change Failure Rate for %s on branch %s", repoFullName, branch)
 
-	workflowRuns, _, err := client.Actions.ListRepositoryWorkflowRuns(context.Background(), getOwner(repoFullName), getRepo(repoFullName), &github.ListWorkflowRunsOptions{
-		Branch:      branch,
-		ListOptions: github.ListOptions{PerPage: 100},
-	})
-	if err != nil {
-		log.Printf("Error fetching workflow runs: %v", err)
-		return 0
+	attempts, listErr := c.provider.ListDeploymentAttempts(repoFullName, branch, c.now().AddDate(0, 0, -cfg.ChangeFailureRateWindowDays))
+	if listErr != nil && !isPartialList(listErr) {
+		return 0, 0, 0, listErr
 	}
 
 	totalDeployments := 0
 	failedDeployments := 0
-	thirtyDaysAgo := time.Now().AddDate(0, 0, -30)
-	for _, run := range workflowRuns.WorkflowRuns {
-		if run.GetCreatedAt().Time.After(thirtyDaysAgo) {
-			totalDeployments++
-			if run.GetConclusion() == "failure" {
-				failedDeployments++
-			}
+	for _, attempt := range attempts {
+		if cfg.ExcludeWorkflows[attempt.Workflow] {
+			continue
+		}
+		totalDeployments++
+		if !attempt.Successful {
+			failedDeployments++
 		}
 	}
 
 	if totalDeployments == 0 {
-		return 0
+		return 0, 0, 0, listErr
 	}
 	failureRate := float64(failedDeployments) / float64(totalDeployments)
-	log.Printf("Calculated Change Failure Rate: %f", failureRate)
-	return failureRate
+	log.Printf("Calculated Change Failure Rate: %f (%d/%d)", failureRate, failedDeployments, totalDeployments)
+	return failureRate, failedDeployments, totalDeployments, listErr
 }
 
-func updatePrometheusMetrics(metrics *DoraMetrics) {
-	deploymentFrequency.WithLabelValues(metrics.Branch).Set(metrics.DeploymentFrequency)
-	leadTimeForChanges.WithLabelValues(metrics.Branch).Set(metrics.LeadTimeForChanges)
-	timeToRestoreService.WithLabelValues(metrics.Branch).Set(metrics.TimeToRestoreService)
-	changeFailureRate.WithLabelValues(metrics.Branch).Set(metrics.ChangeFailureRate)
-	successfulDeployments.WithLabelValues(metrics.Branch).Set(float64(metrics.SuccessfulDeployments))
-	failedDeployments.WithLabelValues(metrics.Branch).Set(float64(metrics.FailedDeployments))
+var errInvalidRepoFullName = errors.New("invalid repository full name")
+
+// parseRepoFullName splits an "owner/repo" name, rejecting anything that does
+// not have exactly two non-empty segments.
+func parseRepoFullName(repoFullName string) (string, string, error) {
+	parts := strings.Split(strings.TrimSpace(repoFullName), "/")
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("%w %q: expected owner/repo", errInvalidRepoFullName, repoFullName)
+	}
+	owner, repo := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+	if owner == "" || repo == "" {
+		return "", "", fmt.Errorf("%w %q: expected owner/repo", errInvalidRepoFullName, repoFullName)
+	}
+	return owner, repo, nil
 }
 
+// getOwner and getRepo expect a name already validated by parseRepoFullName.
 func getOwner(repoFullName string) string {
-	return strings.Split(repoFullName, "/")[0]
+	owner, _, _ := parseRepoFullName(repoFullName)
+	return owner
 }
 
 func getRepo(repoFullName string) string {
-	return strings.Split(repoFullName, "/")[1]
+	_, repo, _ := parseRepoFullName(repoFullName)
+	return repo
 }
 
-func getBranchFromRef(ref string) string {
-	return strings.TrimPrefix(ref, "refs/heads/")
+// parseWebhookSecrets combines the comma-separated WEBHOOK_SECRETS list with
+// the single WEBHOOK_SECRET value, so that secrets can be rotated without
+// downtime.
+func parseWebhookSecrets(secretsList string, secret string) [][]byte {
+	var secrets [][]byte
+	for _, s := range strings.Split(secretsList, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			secrets = append(secrets, []byte(s))
+		}
+	}
+	if secret != "" {
+		secrets = append(secrets, []byte(secret))
+	}
+	return secrets
+}
+
+// getBranchFromRef returns the branch a pushed ref refers to, stripping the
+// first matching BRANCH_REF_PREFIXES entry. Tags are not branches, so ok is
+// false for refs under refs/tags/. Other refs are used unchanged.
+func getBranchFromRef(ref string) (branch string, ok bool) {
+	if strings.HasPrefix(ref, "refs/tags/") {
+		return "", false
+	}
+	for _, prefix := range cfg.BranchRefPrefixes {
+		if strings.HasPrefix(ref, prefix) {
+			return strings.TrimPrefix(ref, prefix), true
+		}
+	}
+	log.Printf("Warning: ref %q matches none of BRANCH_REF_PREFIXES; using it as the branch", ref)
+	return ref, true
 }
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/google/go-github/v45/github"
+)
+
+const (
+	defaultDeploymentManifestFile = "manifest.json"
+	// maxManifestArtifactBytes bounds the artifact archives downloaded.
+	maxManifestArtifactBytes = 1 << 20
+	// maxManifestCacheEntries bounds the memory used by manifestCache.
+	maxManifestCacheEntries = 10000
+)
+
+// deploymentManifest is the file a deploy workflow uploads in its
+// DEPLOYMENT_MANIFEST_ARTIFACT, recording what it deployed where.
+type deploymentManifest struct {
+	Environment string `json:"environment"`
+	Version     string `json:"version"`
+}
+
+// manifestCache holds the manifests of completed runs, which never change, by
+// repo and run ID. Runs without a manifest are cached as nil. Like
+// changedPathsCache it is emptied once it grows too large.
+var manifestCache = struct {
+	mu        sync.Mutex
+	manifests map[string]*deploymentManifest
+}{manifests: make(map[string]*deploymentManifest)}
+
+// runManifest returns the deployment manifest uploaded by the workflow run
+// runID, or nil if the run has no DEPLOYMENT_MANIFEST_ARTIFACT, e.g. because
+// it failed before deploying. An unreadable manifest is an error.
+func (p *githubProvider) runManifest(repoFullName string, runID int64) (*deploymentManifest, error) {
+	key := fmt.Sprintf("%s#%d", repoFullName, runID)
+	manifestCache.mu.Lock()
+	manifest, ok := manifestCache.manifests[key]
+	manifestCache.mu.Unlock()
+	if ok {
+		return manifest, nil
+	}
+
+	manifest, err := p.fetchRunManifest(repoFullName, runID)
+	if err != nil {
+		return nil, err
+	}
+
+	manifestCache.mu.Lock()
+	if len(manifestCache.manifests) >= maxManifestCacheEntries {
+		manifestCache.manifests = make(map[string]*deploymentManifest)
+	}
+	manifestCache.manifests[key] = manifest
+	manifestCache.mu.Unlock()
+	return manifest, nil
+}
+
+func (p *githubProvider) fetchRunManifest(repoFullName string, runID int64) (*deploymentManifest, error) {
+	ctx := context.Background()
+	owner, repo := getOwner(repoFullName), getRepo(repoFullName)
+
+	countGitHubCall("artifacts")
+	artifacts, _, err := p.client.Actions.ListWorkflowRunArtifacts(ctx, owner, repo, runID, &github.ListOptions{PerPage: 100})
+	if err != nil {
+		return nil, fmt.Errorf("fetching artifacts of run %d: %w", runID, err)
+	}
+	var artifact *github.Artifact
+	for _, candidate := range artifacts.Artifacts {
+		if candidate.GetName() == cfg.DeploymentManifestArtifact && !candidate.GetExpired() {
+			artifact = candidate
+		}
+	}
+	if artifact == nil {
+		return nil, nil
+	}
+
+	countGitHubCall("artifacts")
+	downloadURL, _, err := p.client.Actions.DownloadArtifact(ctx, owner, repo, artifact.GetID(), false)
+	if err != nil {
+		return nil, fmt.Errorf("fetching download URL of artifact %d: %w", artifact.GetID(), err)
+	}
+	// The URL is pre-signed, so it is fetched without the GitHub token.
+	resp, err := p.downloadClient.Get(downloadURL.String())
+	if err != nil {
+		return nil, fmt.Errorf("downloading artifact %d: %w", artifact.GetID(), err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("downloading artifact %d: unexpected status %s", artifact.GetID(), resp.Status)
+	}
+	archive, err := io.ReadAll(io.LimitReader(resp.Body, maxManifestArtifactBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("downloading artifact %d: %w", artifact.GetID(), err)
+	}
+	if len(archive) > maxManifestArtifactBytes {
+		return nil, fmt.Errorf("artifact %d is larger than %d bytes", artifact.GetID(), maxManifestArtifactBytes)
+	}
+
+	return parseManifestArchive(archive)
+}
+
+// parseManifestArchive reads DEPLOYMENT_MANIFEST_FILE from a zipped artifact.
+func parseManifestArchive(archive []byte) (*deploymentManifest, error) {
+	reader, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		return nil, fmt.Errorf("reading manifest artifact: %w", err)
+	}
+	file, err := reader.Open(cfg.DeploymentManifestFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading manifest artifact: %w", err)
+	}
+	defer file.Close()
+
+	var manifest deploymentManifest
+	if err := json.NewDecoder(file).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", cfg.DeploymentManifestFile, err)
+	}
+	return &manifest, nil
+}
@@ -0,0 +1,553 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	unhandledWebhookEvents   *prometheus.CounterVec
+	webhookSignatureFailures *prometheus.CounterVec
+	reviewLeadTime           *prometheus.GaugeVec
+	githubAPICalls           *prometheus.CounterVec
+	// The GitHub rate limit gauges are only registered with
+	// GITHUB_RATE_LIMIT_METRICS.
+	githubRateLimit          *prometheus.GaugeVec
+	githubRateLimitRemaining *prometheus.GaugeVec
+	githubRateLimitReset     *prometheus.GaugeVec
+	// The lead time distributions are only registered when listed in
+	// LEAD_TIME_DISTRIBUTION.
+	leadTimeHistogram *prometheus.HistogramVec
+	leadTimeSummary   *prometheus.SummaryVec
+	// deploymentsTotal is only registered with DEPLOYMENT_EXEMPLARS.
+	deploymentsTotal *prometheus.CounterVec
+	// The service gauges are only registered with MONOREPO_SERVICES.
+	serviceDeploymentFrequency  *prometheus.GaugeVec
+	serviceLeadTimeForChanges   *prometheus.GaugeVec
+	serviceTimeToRestoreService *prometheus.GaugeVec
+	serviceChangeFailureRate    *prometheus.GaugeVec
+	// gauges holds the DORA series served from /metrics.
+	gauges *doraGauges
+)
+
+// registerMetrics creates and registers the metrics served from /metrics.
+// Names depend on the configuration, so it is called once it has been loaded.
+func registerMetrics() {
+	unhandledWebhookEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: metricName("unhandled_webhook_events_total"),
+		Help: "Number of webhook deliveries ignored because their event type is not handled",
+	}, []string{"type"})
+	webhookSignatureFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: metricName("webhook_signature_failures_total"),
+		Help: "Number of webhook deliveries rejected because their signature or token was missing or did not match",
+	}, []string{"reason"})
+	reviewLeadTime = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: metricName("review_lead_time_minutes"),
+		Help: fmt.Sprintf("Average time from opening to merging of the pull requests merged in the last %d days, from pull_request webhooks (in minutes)", cfg.WindowDays),
+	}, []string{"branch", "repo"})
+	githubAPICalls = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: metricName("github_api_calls_total"),
+		Help: "Number of GitHub API requests made to calculate metrics, by endpoint",
+	}, []string{"endpoint"})
+	prometheus.MustRegister(unhandledWebhookEvents, webhookSignatureFailures, reviewLeadTime, githubAPICalls)
+	if cfg.GitHubRateLimitMetrics {
+		githubRateLimit = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: metricName("github_rate_limit_limit"),
+			Help: "Number of GitHub API requests allowed per rate limit window, as last reported by GitHub",
+		}, []string{"resource"})
+		githubRateLimitRemaining = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: metricName("github_rate_limit_remaining"),
+			Help: "Number of GitHub API requests left in the current rate limit window, as last reported by GitHub",
+		}, []string{"resource"})
+		githubRateLimitReset = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: metricName("github_rate_limit_reset_timestamp"),
+			Help: "Unix time at which the current GitHub rate limit window resets, as last reported by GitHub",
+		}, []string{"resource"})
+		prometheus.MustRegister(githubRateLimit, githubRateLimitRemaining, githubRateLimitReset)
+	}
+
+	if cfg.LeadTimeDistributions[leadTimeDistributionHistogram] {
+		leadTimeHistogram = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    metricName("lead_time_for_changes_distribution_minutes"),
+			Help:    "Lead time of each successful deployment, observed once per deployment (in minutes)",
+			Buckets: leadTimeHistogramBuckets,
+		}, []string{"branch", "repo"})
+		prometheus.MustRegister(leadTimeHistogram)
+	}
+	if cfg.LeadTimeDistributions[leadTimeDistributionSummary] {
+		leadTimeSummary = prometheus.NewSummaryVec(prometheus.SummaryOpts{
+			Name:       metricName("lead_time_for_changes_quantile_minutes"),
+			Help:       "Quantiles of the lead time of successful deployments, observed once per deployment (in minutes)",
+			Objectives: leadTimeSummaryObjectives,
+			MaxAge:     time.Duration(cfg.LeadTimeWindowDays) * 24 * time.Hour,
+		}, []string{"branch", "repo"})
+		prometheus.MustRegister(leadTimeSummary)
+	}
+	if cfg.DeploymentExemplars {
+		deploymentsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: metricName("deployments_observed_total"),
+			Help: "Number of deployment attempts, counted once when a recalculation first sees them, with the deployed commit as exemplar",
+		}, []string{"branch", "repo", "environment", "result"})
+		prometheus.MustRegister(deploymentsTotal)
+	}
+	if len(cfg.MonorepoServices) > 0 {
+		labels := []string{"branch", "repo", "service"}
+		serviceDeploymentFrequency = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: metricName("service_deployment_frequency"),
+			Help: "Deployment Frequency of each MONOREPO_SERVICES service (deployments per day)",
+		}, labels)
+		serviceLeadTimeForChanges = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: metricName("service_lead_time_for_changes_minutes"),
+			Help: "Lead Time for Changes of each MONOREPO_SERVICES service (in minutes)",
+		}, labels)
+		serviceTimeToRestoreService = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: metricName("service_time_to_restore_service_hours"),
+			Help: "Time to Restore Service of each MONOREPO_SERVICES service (in hours)",
+		}, labels)
+		serviceChangeFailureRate = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: metricName("service_change_failure_rate"),
+			Help: "Change Failure Rate of each MONOREPO_SERVICES service",
+		}, labels)
+		prometheus.MustRegister(serviceDeploymentFrequency, serviceLeadTimeForChanges, serviceTimeToRestoreService, serviceChangeFailureRate)
+	}
+	prometheus.MustRegister(newTrackedSeriesCollector(seenKeys))
+
+	gauges = newDoraGauges()
+	gauges.register(prometheus.DefaultRegisterer)
+}
+
+// trackedSeriesCollector reports how many repo/branches a metricsStore holds,
+// in total and per repo. Counts are read at scrape time so repos whose last
+// branch was removed disappear from the per-repo series.
+type trackedSeriesCollector struct {
+	store  *metricsStore
+	total  *prometheus.Desc
+	byRepo *prometheus.Desc
+}
+
+func newTrackedSeriesCollector(store *metricsStore) *trackedSeriesCollector {
+	return &trackedSeriesCollector{
+		store: store,
+		total: prometheus.NewDesc(metricName("tracked_series"),
+			"Number of repo/branches metrics are tracked for", nil, nil),
+		byRepo: prometheus.NewDesc(metricName("tracked_series_by_repo"),
+			"Number of branches metrics are tracked for, by repo", []string{"repo"}, nil),
+	}
+}
+
+func (c *trackedSeriesCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.total
+	ch <- c.byRepo
+}
+
+func (c *trackedSeriesCollector) Collect(ch chan<- prometheus.Metric) {
+	total := 0
+	for repo, count := range c.store.countByRepo() {
+		total += count
+		ch <- prometheus.MustNewConstMetric(c.byRepo, prometheus.GaugeValue, float64(count), repo)
+	}
+	ch <- prometheus.MustNewConstMetric(c.total, prometheus.GaugeValue, float64(total))
+}
+
+// countGitHubCall records a GitHub API request to endpoint.
+func countGitHubCall(endpoint string) {
+	githubAPICalls.WithLabelValues(endpoint).Inc()
+}
+
+// metricName prefixes name with the configured METRIC_NAMESPACE and
+// METRIC_SUBSYSTEM.
+func metricName(name string) string {
+	return prometheus.BuildFQName(cfg.MetricNamespace, cfg.MetricSubsystem, name)
+}
+
+// doraGauges is the set of gauges DORA metrics are published as. The global
+// set behind /metrics accumulates every repo/branch; /metrics/repo fills a
+// fresh set per request.
+type doraGauges struct {
+	deploymentFrequency            *prometheus.GaugeVec
+	leadTimeForChanges             *prometheus.GaugeVec
+	leadTimeByHotfix               *prometheus.GaugeVec
+	leadTimeSampleCount            *prometheus.GaugeVec
+	timeToRestoreService           *prometheus.GaugeVec
+	changeFailureRate              *prometheus.GaugeVec
+	successfulDeployments          *prometheus.GaugeVec
+	failedDeployments              *prometheus.GaugeVec
+	secondsSinceLastDeployment     *prometheus.GaugeVec
+	deployRecoveryTime             *prometheus.GaugeVec
+	incidentsTotal                 *prometheus.GaugeVec
+	deploymentsByActor             *prometheus.GaugeVec
+	deployedVersion                *prometheus.GaugeVec
+	openIncidentAge                *prometheus.GaugeVec
+	timeToRestoreServiceBySeverity *prometheus.GaugeVec
+	metricsLastUpdated             *prometheus.GaugeVec
+	compositeScore                 *prometheus.GaugeVec
+	pullRequestMergeFrequency      *prometheus.GaugeVec
+	pullRequestLeadTime            *prometheus.GaugeVec
+	weightedDeploymentFrequency    *prometheus.GaugeVec
+	partialMetrics                 *prometheus.GaugeVec
+}
+
+func newDoraGauges() *doraGauges {
+	return &doraGauges{
+		deploymentFrequency: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: metricName("deployment_frequency"),
+			Help: "Deployment Frequency metric (deployments per day)",
+		}, []string{"branch", "repo", "environment"}),
+		weightedDeploymentFrequency: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: metricName("weighted_deployment_frequency"),
+			Help: fmt.Sprintf("Deployment Frequency weighted toward recent deployments with a half-life of %s (deployments per day)", cfg.FrequencyHalfLife),
+		}, []string{"branch", "repo"}),
+		leadTimeForChanges: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: metricName("lead_time_for_changes_minutes"),
+			Help: "Lead Time for Changes metric (in minutes)",
+		}, []string{"branch", "repo"}),
+		leadTimeByHotfix: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: metricName("lead_time_for_changes_by_hotfix_minutes"),
+			Help: "Lead Time for Changes metric (in minutes), for hotfixes and normal changes",
+		}, []string{"branch", "repo", "hotfix"}),
+		leadTimeSampleCount: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: metricName("lead_time_sample_count"),
+			Help: fmt.Sprintf("Number of successful deployments Lead Time for Changes was averaged over in the last %d days", cfg.LeadTimeWindowDays),
+		}, []string{"branch", "repo"}),
+		timeToRestoreService: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: metricName("time_to_restore_service"),
+			Help: "Time to Restore Service metric (in hours)",
+		}, []string{"branch", "repo"}),
+		changeFailureRate: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: metricName("change_failure_rate"),
+			Help: "Change Failure Rate metric (" + metricUnits()[metricChangeFailureRate] + ")",
+		}, []string{"branch", "repo"}),
+		successfulDeployments: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: metricName("successful_deployments"),
+			Help: fmt.Sprintf("Number of successful deployments in the last %d days", cfg.DeploymentFrequencyWindowDays),
+		}, []string{"branch", "repo", "environment"}),
+		failedDeployments: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: metricName("failed_deployments"),
+			Help: fmt.Sprintf("Number of failed deployments in the last %d days", cfg.DeploymentFrequencyWindowDays),
+		}, []string{"branch", "repo", "environment"}),
+		secondsSinceLastDeployment: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: metricName("seconds_since_last_deployment"),
+			Help: fmt.Sprintf("Seconds since the last successful deployment (window length if none in the last %d days)", cfg.DeploymentFrequencyWindowDays),
+		}, []string{"branch", "repo"}),
+		deployRecoveryTime: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: metricName("deploy_recovery_time_minutes"),
+			Help: fmt.Sprintf("Average time from a failed deployment to the next successful one in the same environment over the last %d days (in minutes)", cfg.DeploymentFrequencyWindowDays),
+		}, []string{"branch", "repo"}),
+		incidentsTotal: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: metricName("incidents_total"),
+			Help: fmt.Sprintf("Number of incidents Time to Restore Service was averaged over in the last %d days", cfg.RestoreTimeWindowDays),
+		}, []string{"branch", "repo"}),
+		deploymentsByActor: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: metricName("deployments_total"),
+			Help: fmt.Sprintf("Deployment attempts in the last %d days by triggering user or team", cfg.DeploymentFrequencyWindowDays),
+		}, []string{"branch", "repo", "actor"}),
+		deployedVersion: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: metricName("deployed_version_info"),
+			Help: "Always 1, labeled with the version of the most recent successful deployment to each environment",
+		}, []string{"branch", "repo", "environment", "version"}),
+		openIncidentAge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: metricName("open_incident_age_seconds"),
+			Help: "Seconds the oldest open incident has been open (0 if none)",
+		}, []string{"branch", "repo"}),
+		timeToRestoreServiceBySeverity: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: metricName("time_to_restore_service_by_severity"),
+			Help: "Time to Restore Service in hours for incidents of each severity",
+		}, []string{"branch", "repo", "severity"}),
+		metricsLastUpdated: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: metricName("metrics_last_updated_timestamp"),
+			Help: "Unix time at which the DORA metrics were last recomputed",
+		}, []string{"branch", "repo"}),
+		compositeScore: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: metricName("composite_score"),
+			Help: "Weighted 0-100 roll-up of the four DORA metrics, each scored against the DORA performance levels",
+		}, []string{"branch", "repo"}),
+		pullRequestMergeFrequency: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: metricName("pull_request_merge_frequency"),
+			Help: fmt.Sprintf("Pull requests merged per day over the last %d days", cfg.WindowDays),
+		}, []string{"branch", "repo"}),
+		pullRequestLeadTime: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: metricName("pull_request_lead_time_minutes"),
+			Help: fmt.Sprintf("Average time from opening to merging of the pull requests merged in the last %d days (in minutes)", cfg.WindowDays),
+		}, []string{"branch", "repo"}),
+		partialMetrics: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: metricName("metric_partial"),
+			Help: "1 for each metric last calculated from an incomplete listing because a page failed to fetch",
+		}, []string{"branch", "repo", "metric"}),
+	}
+}
+
+// metricUnits returns the unit each headline metric is reported in, as listed
+// in DoraMetrics.Units.
+func metricUnits() map[string]string {
+	cfrUnit := "ratio 0-1"
+	if cfg.CFRAsPercent {
+		cfrUnit = "percent"
+	}
+	return map[string]string{
+		metricDeploymentFrequency:  "deployments per day",
+		metricLeadTimeForChanges:   "minutes",
+		metricTimeToRestoreService: "hours",
+		metricChangeFailureRate:    cfrUnit,
+		metricDeployRecoveryTime:   "minutes",
+	}
+}
+
+// metricEnabled reports whether the headline metric is calculated and
+// published, as configured by ENABLED_METRICS.
+func metricEnabled(metric string) bool {
+	return cfg.EnabledMetrics == nil || cfg.EnabledMetrics[metric]
+}
+
+// restoreTimeEnabled reports whether Time to Restore Service is calculated
+// and published for repoFullName: it is enabled in ENABLED_METRICS and its
+// restore time source is not "disabled".
+func restoreTimeEnabled(repoFullName string) bool {
+	return metricEnabled(metricTimeToRestoreService) && restoreTimeSource(repoFullName) != restoreTimeSourceDisabled
+}
+
+// changeFailureRateScale is the factor the 0-1 change failure rate is
+// multiplied by when it is published.
+func changeFailureRateScale() float64 {
+	if cfg.CFRAsPercent {
+		return 100
+	}
+	return 1
+}
+
+// deleteSeries removes every series of repo/branch.
+func (g *doraGauges) deleteSeries(key seriesKey) {
+	labels := prometheus.Labels{"branch": key.Branch, "repo": key.Repo}
+	for _, vec := range []*prometheus.GaugeVec{
+		g.deploymentFrequency,
+		g.leadTimeForChanges,
+		g.leadTimeByHotfix,
+		g.leadTimeSampleCount,
+		g.timeToRestoreService,
+		g.changeFailureRate,
+		g.successfulDeployments,
+		g.failedDeployments,
+		g.secondsSinceLastDeployment,
+		g.deployRecoveryTime,
+		g.incidentsTotal,
+		g.deploymentsByActor,
+		g.deployedVersion,
+		g.openIncidentAge,
+		g.timeToRestoreServiceBySeverity,
+		g.metricsLastUpdated,
+		g.compositeScore,
+		g.pullRequestMergeFrequency,
+		g.pullRequestLeadTime,
+		g.weightedDeploymentFrequency,
+		g.partialMetrics,
+	} {
+		vec.DeletePartialMatch(labels)
+	}
+	reviewLeadTime.DeletePartialMatch(labels)
+	if leadTimeHistogram != nil {
+		leadTimeHistogram.DeletePartialMatch(labels)
+	}
+	if deploymentsTotal != nil {
+		deploymentsTotal.DeletePartialMatch(labels)
+	}
+	if leadTimeSummary != nil {
+		leadTimeSummary.DeletePartialMatch(labels)
+	}
+	if len(cfg.MonorepoServices) > 0 {
+		for _, vec := range []*prometheus.GaugeVec{serviceDeploymentFrequency, serviceLeadTimeForChanges, serviceTimeToRestoreService, serviceChangeFailureRate} {
+			vec.DeletePartialMatch(labels)
+		}
+	}
+}
+
+func (g *doraGauges) register(registerer prometheus.Registerer) {
+	collectors := []prometheus.Collector{
+		g.openIncidentAge,
+		g.metricsLastUpdated,
+		g.compositeScore,
+		g.pullRequestMergeFrequency,
+		g.pullRequestLeadTime,
+		g.partialMetrics,
+	}
+	// The gauges of metrics left out of ENABLED_METRICS are not served.
+	if metricEnabled(metricDeploymentFrequency) {
+		collectors = append(collectors,
+			g.deploymentFrequency,
+			g.successfulDeployments,
+			g.failedDeployments,
+			g.secondsSinceLastDeployment,
+			g.deployRecoveryTime,
+			g.deploymentsByActor,
+			g.deployedVersion,
+		)
+		if cfg.FrequencyHalfLife > 0 {
+			collectors = append(collectors, g.weightedDeploymentFrequency)
+		}
+	}
+	if metricEnabled(metricLeadTimeForChanges) {
+		collectors = append(collectors, g.leadTimeForChanges, g.leadTimeByHotfix, g.leadTimeSampleCount)
+	}
+	if metricEnabled(metricTimeToRestoreService) {
+		collectors = append(collectors, g.timeToRestoreService, g.incidentsTotal, g.timeToRestoreServiceBySeverity)
+	}
+	if metricEnabled(metricChangeFailureRate) {
+		collectors = append(collectors, g.changeFailureRate)
+	}
+	registerer.MustRegister(collectors...)
+}
+
+// metricGauges returns the gauges published from the sub-metric metric, as
+// named in DoraMetrics.Errors.
+func (g *doraGauges) metricGauges(metric string) []*prometheus.GaugeVec {
+	switch metric {
+	case metricDeploymentFrequency:
+		return []*prometheus.GaugeVec{
+			g.deploymentFrequency,
+			g.successfulDeployments,
+			g.failedDeployments,
+			g.secondsSinceLastDeployment,
+			g.deployRecoveryTime,
+			g.weightedDeploymentFrequency,
+			g.deployedVersion,
+			g.deploymentsByActor,
+		}
+	case metricLeadTimeForChanges:
+		return []*prometheus.GaugeVec{g.leadTimeForChanges, g.leadTimeByHotfix, g.leadTimeSampleCount}
+	case metricTimeToRestoreService:
+		return []*prometheus.GaugeVec{g.timeToRestoreService, g.incidentsTotal, g.timeToRestoreServiceBySeverity}
+	case metricChangeFailureRate:
+		return []*prometheus.GaugeVec{g.changeFailureRate}
+	case metricOpenIncidents:
+		return []*prometheus.GaugeVec{g.openIncidentAge}
+	case metricPullRequests:
+		return []*prometheus.GaugeVec{g.pullRequestMergeFrequency, g.pullRequestLeadTime}
+	}
+	return nil
+}
+
+// update sets the gauges of the repo/branch of metrics, which were computed at
+// computedAt.
+func (g *doraGauges) update(metrics *DoraMetrics, computedAt time.Time) {
+	// Metrics that failed are removed rather than published as 0, which
+	// would read as e.g. a perfect change failure rate.
+	for metric := range metrics.Errors {
+		for _, vec := range g.metricGauges(metric) {
+			vec.DeletePartialMatch(prometheus.Labels{"branch": metrics.Branch, "repo": metrics.Repo})
+		}
+	}
+	failed := func(metric string) bool {
+		_, ok := metrics.Errors[metric]
+		return ok
+	}
+
+	if metricEnabled(metricDeploymentFrequency) && !failed(metricDeploymentFrequency) {
+		g.updateDeployments(metrics)
+	}
+	// Metrics from too few samples are removed rather than left at a value
+	// from an earlier window.
+	if metricEnabled(metricLeadTimeForChanges) && !failed(metricLeadTimeForChanges) {
+		if slices.Contains(metrics.InsufficientSamples, metricLeadTimeForChanges) {
+			g.leadTimeForChanges.DeleteLabelValues(metrics.Branch, metrics.Repo)
+			g.leadTimeByHotfix.DeletePartialMatch(prometheus.Labels{"branch": metrics.Branch, "repo": metrics.Repo})
+		} else {
+			g.leadTimeForChanges.WithLabelValues(metrics.Branch, metrics.Repo).Set(metrics.LeadTimeForChanges)
+			// A kind of change without deployments has no lead time,
+			// rather than one of 0 minutes.
+			setOrDeleteGauge(g.leadTimeByHotfix, metrics.LeadTimeSampleCount > metrics.HotfixCount, metrics.LeadTimeForNormalChanges, metrics.Branch, metrics.Repo, "false")
+			setOrDeleteGauge(g.leadTimeByHotfix, metrics.HotfixCount > 0, metrics.LeadTimeForHotfixes, metrics.Branch, metrics.Repo, "true")
+		}
+		g.leadTimeSampleCount.WithLabelValues(metrics.Branch, metrics.Repo).Set(float64(metrics.LeadTimeSampleCount))
+	}
+	if metricEnabled(metricTimeToRestoreService) && !restoreTimeEnabled(metrics.Repo) {
+		// Repos without incident tracking have no restore time series.
+		labels := prometheus.Labels{"branch": metrics.Branch, "repo": metrics.Repo}
+		g.timeToRestoreService.DeletePartialMatch(labels)
+		g.incidentsTotal.DeletePartialMatch(labels)
+		g.timeToRestoreServiceBySeverity.DeletePartialMatch(labels)
+	} else if metricEnabled(metricTimeToRestoreService) && !failed(metricTimeToRestoreService) {
+		if slices.Contains(metrics.InsufficientSamples, metricTimeToRestoreService) {
+			g.timeToRestoreService.DeleteLabelValues(metrics.Branch, metrics.Repo)
+		} else {
+			g.timeToRestoreService.WithLabelValues(metrics.Branch, metrics.Repo).Set(metrics.TimeToRestoreService)
+		}
+		g.incidentsTotal.WithLabelValues(metrics.Branch, metrics.Repo).Set(float64(metrics.IncidentCount))
+		// Drop severities that no longer have incidents in the window.
+		g.timeToRestoreServiceBySeverity.DeletePartialMatch(prometheus.Labels{"branch": metrics.Branch, "repo": metrics.Repo})
+		for severity, hours := range metrics.TimeToRestoreBySeverity {
+			g.timeToRestoreServiceBySeverity.WithLabelValues(metrics.Branch, metrics.Repo, severity).Set(hours)
+		}
+	}
+	if metricEnabled(metricChangeFailureRate) && !failed(metricChangeFailureRate) {
+		if slices.Contains(metrics.InsufficientSamples, metricChangeFailureRate) {
+			g.changeFailureRate.DeleteLabelValues(metrics.Branch, metrics.Repo)
+		} else {
+			g.changeFailureRate.WithLabelValues(metrics.Branch, metrics.Repo).Set(metrics.ChangeFailureRate)
+		}
+	}
+	if cfg.IncludeOpenIncidents && !failed(metricOpenIncidents) {
+		g.openIncidentAge.WithLabelValues(metrics.Branch, metrics.Repo).Set(metrics.OpenIncidentAgeSeconds)
+	}
+	if metrics.PullRequests != nil {
+		g.pullRequestMergeFrequency.WithLabelValues(metrics.Branch, metrics.Repo).Set(metrics.PullRequests.MergeFrequency)
+		g.pullRequestLeadTime.WithLabelValues(metrics.Branch, metrics.Repo).Set(metrics.PullRequests.LeadTime)
+	}
+	g.compositeScore.WithLabelValues(metrics.Branch, metrics.Repo).Set(metrics.CompositeScore)
+	// Drop metrics that were complete this time.
+	g.partialMetrics.DeletePartialMatch(prometheus.Labels{"branch": metrics.Branch, "repo": metrics.Repo})
+	for metric := range metrics.Partial {
+		g.partialMetrics.WithLabelValues(metrics.Branch, metrics.Repo, metric).Set(1)
+	}
+	g.metricsLastUpdated.WithLabelValues(metrics.Branch, metrics.Repo).Set(float64(computedAt.Unix()))
+}
+
+// setOrDeleteGauge sets the series of vec with labels to value if ok, and
+// removes it otherwise.
+func setOrDeleteGauge(vec *prometheus.GaugeVec, ok bool, value float64, labels ...string) {
+	if !ok {
+		vec.DeleteLabelValues(labels...)
+		return
+	}
+	vec.WithLabelValues(labels...).Set(value)
+}
+
+// updateDeployments sets the gauges derived from the deployment attempts.
+func (g *doraGauges) updateDeployments(metrics *DoraMetrics) {
+	// Drop environments that no longer deployed in the window. Without any
+	// deployments the default environment reads 0 rather than disappearing.
+	labels := prometheus.Labels{"branch": metrics.Branch, "repo": metrics.Repo}
+	for _, vec := range []*prometheus.GaugeVec{g.deploymentFrequency, g.successfulDeployments, g.failedDeployments} {
+		vec.DeletePartialMatch(labels)
+	}
+	environments := metrics.Environments
+	if len(environments) == 0 {
+		environments = map[string]*EnvironmentDeployments{defaultEnvironment: {}}
+	}
+	for environment, env := range environments {
+		g.deploymentFrequency.WithLabelValues(metrics.Branch, metrics.Repo, environment).Set(env.DeploymentFrequency)
+		g.successfulDeployments.WithLabelValues(metrics.Branch, metrics.Repo, environment).Set(float64(env.SuccessfulDeployments))
+		g.failedDeployments.WithLabelValues(metrics.Branch, metrics.Repo, environment).Set(float64(env.FailedDeployments))
+	}
+	g.secondsSinceLastDeployment.WithLabelValues(metrics.Branch, metrics.Repo).Set(metrics.SecondsSinceLastDeployment)
+	g.deployRecoveryTime.WithLabelValues(metrics.Branch, metrics.Repo).Set(metrics.DeployRecoveryTime)
+	if cfg.FrequencyHalfLife > 0 {
+		g.weightedDeploymentFrequency.WithLabelValues(metrics.Branch, metrics.Repo).Set(metrics.WeightedDeploymentFrequency)
+	}
+	if cfg.DeploymentManifestArtifact != "" {
+		// Only the latest version of each environment is kept.
+		g.deployedVersion.DeletePartialMatch(prometheus.Labels{"branch": metrics.Branch, "repo": metrics.Repo})
+		for environment, env := range metrics.Environments {
+			if env.LatestVersion != "" {
+				g.deployedVersion.WithLabelValues(metrics.Branch, metrics.Repo, environment, env.LatestVersion).Set(1)
+			}
+		}
+	}
+	if cfg.DeploymentsByActor {
+		// Drop actors that no longer deployed in the window.
+		g.deploymentsByActor.DeletePartialMatch(prometheus.Labels{"branch": metrics.Branch, "repo": metrics.Repo})
+		for actor, count := range metrics.DeploymentsByActor {
+			g.deploymentsByActor.WithLabelValues(metrics.Branch, metrics.Repo, actor).Set(float64(count))
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUpdateRemovesGaugesOfFailedMetrics(t *testing.T) {
+	setupWebhookTest(t)
+	g := newDoraGauges()
+	metrics := &DoraMetrics{Repo: "acme/api", Branch: "main", ChangeFailureRate: 0.25, DeploymentAttempts: 4}
+	g.update(metrics, time.Now())
+	if !g.changeFailureRate.DeleteLabelValues("main", "acme/api") {
+		t.Fatal("change failure rate not published")
+	}
+
+	g.update(metrics, time.Now())
+	failed := &DoraMetrics{Repo: "acme/api", Branch: "main", Errors: map[string]string{metricChangeFailureRate: "fetching runs: 502 Bad Gateway"}}
+	g.update(failed, time.Now())
+	if g.changeFailureRate.DeleteLabelValues("main", "acme/api") {
+		t.Error("change failure rate of a failed calculation published")
+	}
+	if !g.leadTimeSampleCount.DeleteLabelValues("main", "acme/api") {
+		t.Error("lead time of a successful calculation removed")
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// monorepoService is a service of a monorepo, identified by the directory
+// its code lives in.
+type monorepoService struct {
+	// Repo is the lowercased owner/name the service belongs to, or empty
+	// for a service of every repo.
+	Repo string
+	Name string
+	Path string
+}
+
+// parseMonorepoServices parses MONOREPO_SERVICES: comma-separated
+// name=path pairs, each optionally prefixed with "owner/name:" to limit the
+// service to one repo.
+func parseMonorepoServices(list string) ([]monorepoService, error) {
+	var services []monorepoService
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		var service monorepoService
+		if repo, rest, ok := strings.Cut(entry, ":"); ok {
+			owner, name, err := parseRepoFullName(repo)
+			if err != nil {
+				return nil, err
+			}
+			service.Repo = strings.ToLower(owner + "/" + name)
+			entry = rest
+		}
+		name, dir, ok := strings.Cut(entry, "=")
+		service.Name = strings.TrimSpace(name)
+		service.Path = strings.Trim(strings.TrimSpace(dir), "/")
+		if !ok || service.Name == "" || service.Path == "" {
+			return nil, fmt.Errorf("expected [owner/name:]service=path, got %q", entry)
+		}
+		services = append(services, service)
+	}
+	return services, nil
+}
+
+// servicesOf returns the MONOREPO_SERVICES of repoFullName.
+func servicesOf(repoFullName string) []monorepoService {
+	var services []monorepoService
+	for _, service := range cfg.MonorepoServices {
+		if service.Repo == "" || service.Repo == strings.ToLower(repoFullName) {
+			services = append(services, service)
+		}
+	}
+	return services
+}
+
+// serviceProvider limits what a Provider returns to one service: deployments
+// and runs whose commit changed a file under the service's path, and
+// incidents labeled with the service's name.
+type serviceProvider struct {
+	Provider
+	service monorepoService
+}
+
+// deploys reports whether the commit sha changed the service. Deployments
+// whose commit is unknown cannot be attributed to a service.
+func (p *serviceProvider) deploys(repoFullName string, sha string) (bool, error) {
+	if sha == "" {
+		return false, nil
+	}
+	prefix := p.service.Path + "/"
+	return touchesPaths(p.Provider, repoFullName, sha, "service:"+p.service.Path, func(file string) bool {
+		return strings.HasPrefix(file, prefix)
+	})
+}
+
+func (p *serviceProvider) ListDeploymentAttempts(repoFullName string, branch string, since time.Time) ([]deploymentAttempt, error) {
+	attempts, listErr := p.Provider.ListDeploymentAttempts(repoFullName, branch, since)
+	if listErr != nil && !isPartialList(listErr) {
+		return nil, listErr
+	}
+	var filtered []deploymentAttempt
+	for _, attempt := range attempts {
+		deploys, err := p.deploys(repoFullName, attempt.HeadSHA)
+		if err != nil {
+			return nil, err
+		}
+		if deploys {
+			filtered = append(filtered, attempt)
+		}
+	}
+	return filtered, listErr
+}
+
+func (p *serviceProvider) ListPipelineRuns(repoFullName string, branch string, since time.Time) ([]pipelineRun, error) {
+	runs, listErr := p.Provider.ListPipelineRuns(repoFullName, branch, since)
+	if listErr != nil && !isPartialList(listErr) {
+		return nil, listErr
+	}
+	var filtered []pipelineRun
+	for _, run := range runs {
+		deploys, err := p.deploys(repoFullName, run.HeadSHA)
+		if err != nil {
+			return nil, err
+		}
+		if deploys {
+			filtered = append(filtered, run)
+		}
+	}
+	return filtered, listErr
+}
+
+func (p *serviceProvider) ListIncidents(repoFullName string, since time.Time) ([]incident, error) {
+	incidents, listErr := p.Provider.ListIncidents(repoFullName, since)
+	if listErr != nil && !isPartialList(listErr) {
+		return nil, listErr
+	}
+	return p.serviceIncidents(incidents), listErr
+}
+
+func (p *serviceProvider) ListOpenIncidents(repoFullName string) ([]incident, error) {
+	incidents, listErr := p.Provider.ListOpenIncidents(repoFullName)
+	if listErr != nil && !isPartialList(listErr) {
+		return nil, listErr
+	}
+	return p.serviceIncidents(incidents), listErr
+}
+
+func (p *serviceProvider) serviceIncidents(incidents []incident) []incident {
+	var filtered []incident
+	for _, incident := range incidents {
+		if slices.Contains(incident.Labels, p.service.Name) {
+			filtered = append(filtered, incident)
+		}
+	}
+	return filtered
+}
+
+// recomputeServiceMetrics calculates the metrics of every service of the
+// repo/branch and publishes them as the dora_service_* gauges.
+func recomputeServiceMetrics(ctx context.Context, calculator *Calculator, key seriesKey) {
+	for _, service := range servicesOf(key.Repo) {
+		serviceCalculator := calculator.withProvider(&serviceProvider{Provider: calculator.provider, service: service})
+		metrics, err := serviceCalculator.Compute(ctx, key.Repo, key.Branch)
+		if err != nil {
+			log.Printf("Error calculating DORA metrics for service %s of %s on branch %s: %v", service.Name, key.Repo, key.Branch, err)
+			continue
+		}
+		updateServiceGauges(service.Name, metrics)
+	}
+}
+
+// serviceRestoreTimeEnabled reports whether the restore time of the services
+// of repoFullName is published. Deployment statuses and PagerDuty incidents
+// cannot be attributed to a service, so with those sources every service
+// would report the restore time of the whole repo.
+func serviceRestoreTimeEnabled(repoFullName string) bool {
+	switch restoreTimeSource(repoFullName) {
+	case restoreTimeSourceDeployments, restoreTimeSourcePagerDuty:
+		return false
+	}
+	return restoreTimeEnabled(repoFullName)
+}
+
+// updateServiceGauges sets the gauges of service from its metrics. Metrics
+// that failed, are disabled or had too few samples are removed.
+func updateServiceGauges(service string, metrics *DoraMetrics) {
+	for _, gauge := range []struct {
+		metric string
+		vec    *prometheus.GaugeVec
+		value  float64
+	}{
+		{metricDeploymentFrequency, serviceDeploymentFrequency, metrics.DeploymentFrequency},
+		{metricLeadTimeForChanges, serviceLeadTimeForChanges, metrics.LeadTimeForChanges},
+		{metricTimeToRestoreService, serviceTimeToRestoreService, metrics.TimeToRestoreService},
+		{metricChangeFailureRate, serviceChangeFailureRate, metrics.ChangeFailureRate},
+	} {
+		_, failed := metrics.Errors[gauge.metric]
+		published := !failed && metricEnabled(gauge.metric) && !slices.Contains(metrics.InsufficientSamples, gauge.metric)
+		if gauge.metric == metricTimeToRestoreService {
+			published = published && serviceRestoreTimeEnabled(metrics.Repo)
+		}
+		if !published {
+			gauge.vec.DeleteLabelValues(metrics.Branch, metrics.Repo, service)
+			continue
+		}
+		gauge.vec.WithLabelValues(metrics.Branch, metrics.Repo, service).Set(gauge.value)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// observationSets record, per repo/branch, the deployments or runs observed
+// into a counter or distribution, by identity. Every recalculation lists the
+// whole window again, so only the ones not observed before are observed,
+// including those a partial listing missed earlier. Identities are dropped
+// once they completed before the window, when they can no longer be listed.
+type observationSets struct {
+	mu   sync.Mutex
+	sets map[seriesKey]*observationSet
+}
+
+type observationSet struct {
+	// completedAt holds the completion time of every observed identity.
+	completedAt map[string]time.Time
+	// prunedBefore is the window start the set was last pruned with.
+	prunedBefore time.Time
+}
+
+func newObservationSets() *observationSets {
+	return &observationSets{sets: make(map[seriesKey]*observationSet)}
+}
+
+// observe reports whether the deployment or run id of key, completed at
+// completedAt, has not been observed yet, and if so records it. since is the
+// start of the window it was listed from.
+func (c *observationSets) observe(key seriesKey, id string, completedAt time.Time, since time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	set, ok := c.sets[key]
+	if !ok {
+		set = &observationSet{completedAt: make(map[string]time.Time)}
+		c.sets[key] = set
+	}
+	if since.After(set.prunedBefore) {
+		for observed, at := range set.completedAt {
+			if at.Before(since) {
+				delete(set.completedAt, observed)
+			}
+		}
+		set.prunedBefore = since
+	}
+	if _, ok := set.completedAt[id]; ok {
+		return false
+	}
+	set.completedAt[id] = completedAt
+	return true
+}
+
+// forget drops the observations of key, so that its deployments are observed
+// again if the series comes back.
+func (c *observationSets) forget(key seriesKey) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.sets, key)
+}
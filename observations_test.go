@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestObservationSetsObserveEachAttemptOnce(t *testing.T) {
+	observations := newObservationSets()
+	key := seriesKey{Repo: "acme/api", Branch: "main"}
+	since := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	completedAt := since.Add(48 * time.Hour)
+	staging := deploymentAttempt{Environment: "staging", CreatedAt: completedAt.Add(-time.Minute), CompletedAt: completedAt}
+	production := staging
+	production.Environment = "production"
+	late := deploymentAttempt{Environment: "production", CreatedAt: since.Add(time.Hour), CompletedAt: since.Add(2 * time.Hour)}
+
+	// Deployments completed in the same second are told apart.
+	for _, attempt := range []deploymentAttempt{staging, production} {
+		if !observations.observe(key, attemptIdentity(attempt), attempt.CompletedAt, since) {
+			t.Errorf("%s deployment not observed", attempt.Environment)
+		}
+	}
+	// An older deployment missing from an earlier, partial listing is
+	// still observed.
+	if !observations.observe(key, attemptIdentity(late), late.CompletedAt, since) {
+		t.Error("deployment listed late not observed")
+	}
+	for _, attempt := range []deploymentAttempt{staging, production, late} {
+		if observations.observe(key, attemptIdentity(attempt), attempt.CompletedAt, since) {
+			t.Errorf("%s deployment at %s observed twice", attempt.Environment, attempt.CompletedAt)
+		}
+	}
+}
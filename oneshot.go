@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+)
+
+// runOnce computes the metrics of repo/branch, writes them to out as JSON and
+// returns the process exit code: 0 on success, 1 if the metrics could not be
+// computed or any of them failed.
+func runOnce(calculator *Calculator, repoFullName string, branch string, out io.Writer) int {
+	metrics, err := calculator.Compute(context.Background(), repoFullName, branch)
+	if err != nil {
+		log.Printf("Error calculating DORA metrics: %v", err)
+		return 1
+	}
+
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(metrics); err != nil {
+		log.Printf("Error encoding metrics to JSON: %v", err)
+		return 1
+	}
+
+	if len(metrics.Errors) > 0 {
+		log.Printf("%d of the DORA metrics could not be calculated", len(metrics.Errors))
+		return 1
+	}
+	return 0
+}
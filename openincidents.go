@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+
+	"github.com/google/go-github/v45/github"
+)
+
+// openIncidents returns how many incidents mentioning branch are
+// still open and how long, in seconds, the oldest of them has been open.
+func (c *Calculator) openIncidents(repoFullName string, branch string) (float64, int, error) {
+	log.Printf("Calculating open incidents for %s on branch %s", repoFullName, branch)
+
+	incidents, listErr := c.provider.ListOpenIncidents(repoFullName)
+	if listErr != nil && !isPartialList(listErr) {
+		return 0, 0, listErr
+	}
+
+	now := c.now()
+	var oldestAge float64
+	count := 0
+	for _, incident := range incidents {
+		if !strings.Contains(incident.Body, branch) {
+			continue
+		}
+		count++
+		if age := now.Sub(incident.CreatedAt).Seconds(); age > oldestAge {
+			oldestAge = age
+		}
+	}
+	return oldestAge, count, listErr
+}
+
+func (p *githubProvider) ListOpenIncidents(repoFullName string) ([]incident, error) {
+	countGitHubCall("issues")
+	issues, _, err := p.client.Issues.ListByRepo(context.Background(), getOwner(repoFullName), getRepo(repoFullName), &github.IssueListByRepoOptions{
+		State:       "open",
+		Labels:      []string{"incident"},
+		ListOptions: github.ListOptions{PerPage: 100},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("fetching open incident issues: %w", err)
+	}
+
+	incidents := make([]incident, 0, len(issues))
+	for _, issue := range issues {
+		incidents = append(incidents, incident{
+			CreatedAt: issue.GetCreatedAt(),
+			Body:      issue.GetBody(),
+		})
+	}
+	return incidents, nil
+}
+
+func (p *gitlabProvider) ListOpenIncidents(repoFullName string) ([]incident, error) {
+	var issues []gitlabIssue
+	listErr := p.getAll(repoFullName, "/issues", url.Values{
+		"state":    {"opened"},
+		"labels":   {"incident"},
+		"per_page": {"100"},
+	}, &issues)
+	if listErr != nil {
+		listErr = fmt.Errorf("fetching open incident issues: %w", listErr)
+		if !isPartialList(listErr) {
+			return nil, listErr
+		}
+	}
+
+	incidents := make([]incident, 0, len(issues))
+	for _, issue := range issues {
+		incidents = append(incidents, incident{
+			CreatedAt: issue.CreatedAt,
+			Body:      issue.Description,
+		})
+	}
+	return incidents, listErr
+}
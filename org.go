@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-github/v45/github"
+)
+
+// orgRepositories returns the production branch of every repository of org
+// that passes the ORG_TOPICS, ORG_LANGUAGES and ORG_INCLUDE_ARCHIVED filters,
+// so that new repositories are picked up without configuration changes.
+func (p *githubProvider) orgRepositories(org string) ([]seriesKey, error) {
+	opts := &github.RepositoryListByOrgOptions{
+		Type:        "all",
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+	var keys []seriesKey
+	for {
+		countGitHubCall("org_repositories")
+		repositories, resp, err := p.client.Repositories.ListByOrg(context.Background(), org, opts)
+		if err != nil {
+			return nil, fmt.Errorf("fetching repositories of %s: %w", org, err)
+		}
+		for _, repository := range repositories {
+			if !includeOrgRepository(repository) {
+				continue
+			}
+			branch := cfg.ProductionBranch
+			if branch == "" {
+				branch = repository.GetDefaultBranch()
+			}
+			keys = append(keys, seriesKey{Repo: repository.GetFullName(), Branch: branch})
+		}
+		if resp.NextPage == 0 {
+			return keys, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// includeOrgRepository reports whether metrics are computed for repository
+// when discovering the repositories of ORG.
+func includeOrgRepository(repository *github.Repository) bool {
+	if repository.GetDisabled() || repository.GetDefaultBranch() == "" {
+		return false
+	}
+	if repository.GetArchived() && !cfg.OrgIncludeArchived {
+		return false
+	}
+	if cfg.OrgLanguages != nil && !cfg.OrgLanguages[strings.ToLower(repository.GetLanguage())] {
+		return false
+	}
+	if cfg.OrgTopics != nil {
+		for _, topic := range repository.Topics {
+			if cfg.OrgTopics[topic] {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
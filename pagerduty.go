@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// pagerDutyPageSize is the number of incidents requested per page, the
+// maximum the PagerDuty API allows.
+const pagerDutyPageSize = 100
+
+// pagerDutyClient lists the incidents of PagerDuty services through the
+// REST API, authenticated with an API token.
+type pagerDutyClient struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+func newPagerDutyClient(baseURL string, token string) *pagerDutyClient {
+	return &pagerDutyClient{
+		baseURL:    baseURL,
+		token:      token,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// pagerDutyIncident is the part of a PagerDuty incident used for the restore
+// time.
+type pagerDutyIncident struct {
+	IncidentNumber int       `json:"incident_number"`
+	CreatedAt      time.Time `json:"created_at"`
+	// ResolvedAt is missing from incidents resolved before PagerDuty added
+	// it, whose last status change was their resolution.
+	ResolvedAt         *time.Time `json:"resolved_at"`
+	LastStatusChangeAt time.Time  `json:"last_status_change_at"`
+	Urgency            string     `json:"urgency"`
+	Priority           *struct {
+		Summary string `json:"summary"`
+	} `json:"priority"`
+}
+
+// listResolvedIncidents returns the resolved incidents of serviceID
+// triggered between since and until. Their urgency and priority are returned
+// as labels, so that INCIDENT_SEVERITY_WEIGHTS can weight them.
+func (c *pagerDutyClient) listResolvedIncidents(serviceID string, since time.Time, until time.Time) ([]incident, error) {
+	var incidents []incident
+	for offset := 0; ; offset += pagerDutyPageSize {
+		query := url.Values{
+			"service_ids[]": {serviceID},
+			"statuses[]":    {"resolved"},
+			"since":         {since.UTC().Format(time.RFC3339)},
+			"until":         {until.UTC().Format(time.RFC3339)},
+			"time_zone":     {"UTC"},
+			"limit":         {strconv.Itoa(pagerDutyPageSize)},
+			"offset":        {strconv.Itoa(offset)},
+		}
+		var page struct {
+			Incidents []pagerDutyIncident `json:"incidents"`
+			More      bool                `json:"more"`
+		}
+		if err := c.get("/incidents?"+query.Encode(), &page); err != nil {
+			return nil, fmt.Errorf("fetching PagerDuty incidents: %w", err)
+		}
+		for _, pd := range page.Incidents {
+			resolvedAt := pd.LastStatusChangeAt
+			if pd.ResolvedAt != nil {
+				resolvedAt = *pd.ResolvedAt
+			}
+			var labels []string
+			if pd.Urgency != "" {
+				labels = append(labels, pd.Urgency)
+			}
+			if pd.Priority != nil && pd.Priority.Summary != "" {
+				labels = append(labels, pd.Priority.Summary)
+			}
+			incidents = append(incidents, incident{
+				Number:    pd.IncidentNumber,
+				CreatedAt: pd.CreatedAt,
+				ClosedAt:  resolvedAt,
+				Labels:    labels,
+			})
+		}
+		if !page.More || len(page.Incidents) == 0 {
+			return incidents, nil
+		}
+	}
+}
+
+func (c *pagerDutyClient) get(path string, v any) error {
+	req, err := http.NewRequest(http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Token token="+c.token)
+	req.Header.Set("Accept", "application/vnd.pagerduty+json;version=2")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// pagerDutyRestoreTimeSource reports whether RESTORE_TIME_SOURCE or
+// REPO_RESTORE_TIME_SOURCES reads incidents from PagerDuty.
+func pagerDutyRestoreTimeSource() bool {
+	if cfg.RestoreTimeSource == restoreTimeSourcePagerDuty {
+		return true
+	}
+	for _, source := range cfg.RepoRestoreTimeSources {
+		if source == restoreTimeSourcePagerDuty {
+			return true
+		}
+	}
+	return false
+}
+
+// timeToRestoreFromPagerDuty measures, in hours, the average time from the
+// triggering to the resolution of the incidents of the repo's
+// PAGERDUTY_SERVICES service that were triggered in the restore time window.
+// PagerDuty incidents are not tied to a branch, so every branch of the repo
+// gets the same restore time.
+func (c *Calculator) timeToRestoreFromPagerDuty(repoFullName string, branch string) (*restoreStats, error) {
+	log.Printf("Calculating Time to Restore Service from PagerDuty for %s on branch %s", repoFullName, branch)
+
+	serviceID, ok := cfg.PagerDutyServices[strings.ToLower(repoFullName)]
+	if !ok {
+		return nil, fmt.Errorf("no PAGERDUTY_SERVICES entry for %s", repoFullName)
+	}
+	now := c.now()
+	incidents, err := c.pagerDuty.listResolvedIncidents(serviceID, now.AddDate(0, 0, -cfg.RestoreTimeWindowDays), now)
+	if err != nil {
+		return nil, err
+	}
+
+	stats, err := incidentRestoreStats(incidents, func(incident incident) (time.Time, error) {
+		return incident.ClosedAt, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if stats.Incidents > 0 {
+		log.Printf("Calculated Time to Restore Service: %f hours over %d PagerDuty incidents", stats.Hours, stats.Incidents)
+	}
+	return stats, nil
+}
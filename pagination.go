@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/google/go-github/v45/github"
+)
+
+const defaultPaginationConcurrency = 4
+
+// partialListError is returned together with what a listing fetched before
+// one of its later pages failed, so that the metrics can still be calculated
+// from it.
+type partialListError struct {
+	err error
+}
+
+func (e *partialListError) Error() string {
+	return "incomplete listing: " + e.err.Error()
+}
+
+func (e *partialListError) Unwrap() error {
+	return e.err
+}
+
+// isPartialList reports whether err only means that the listing returned
+// with it is incomplete.
+func isPartialList(err error) bool {
+	var partial *partialListError
+	return errors.As(err, &partial)
+}
+
+// listWorkflowRuns returns every workflow run matching opts, only those of
+// DEPLOYMENT_WORKFLOW_FILE when it is set. The first page
+// tells how many pages there are; the rest are then fetched with up to
+// PAGINATION_CONCURRENCY requests in flight, which still go through the
+// GITHUB_REQUESTS_PER_HOUR limiter. The runs are returned in page order.
+// If a page after the first fails, the runs of the other pages are returned
+// with a *partialListError.
+func (p *githubProvider) listWorkflowRuns(repoFullName string, opts *github.ListWorkflowRunsOptions) ([]*github.WorkflowRun, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	owner, repo := getOwner(repoFullName), getRepo(repoFullName)
+
+	fetch := func(page int) ([]*github.WorkflowRun, *github.Response, error) {
+		pageOpts := *opts
+		pageOpts.Page = page
+		countGitHubCall("workflow_runs")
+		var runs *github.WorkflowRuns
+		var resp *github.Response
+		var err error
+		if cfg.DeploymentWorkflowFile != "" {
+			runs, resp, err = p.client.Actions.ListWorkflowRunsByFileName(ctx, owner, repo, cfg.DeploymentWorkflowFile, &pageOpts)
+		} else {
+			runs, resp, err = p.client.Actions.ListRepositoryWorkflowRuns(ctx, owner, repo, &pageOpts)
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("fetching workflow runs page %d: %w", page, err)
+		}
+		return runs.WorkflowRuns, resp, nil
+	}
+
+	first, resp, err := fetch(1)
+	if err != nil {
+		return nil, err
+	}
+	if resp.LastPage <= 1 {
+		return first, nil
+	}
+
+	pages := make([][]*github.WorkflowRun, resp.LastPage+1)
+	pages[1] = first
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	var errOnce sync.Once
+	var firstErr error
+	for i := 0; i < cfg.PaginationConcurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for page := range jobs {
+				runs, _, err := fetch(page)
+				if err != nil {
+					errOnce.Do(func() { firstErr = err })
+					// Stop the other requests once rate limited rather
+					// than spend more of the limit on failing pages.
+					var rateLimitErr *github.RateLimitError
+					var abuseErr *github.AbuseRateLimitError
+					if errors.As(err, &rateLimitErr) || errors.As(err, &abuseErr) {
+						cancel()
+					}
+					continue
+				}
+				pages[page] = runs
+			}
+		}()
+	}
+	for page := 2; page <= resp.LastPage && ctx.Err() == nil; page++ {
+		jobs <- page
+	}
+	close(jobs)
+	wg.Wait()
+
+	var runs []*github.WorkflowRun
+	for _, page := range pages {
+		runs = append(runs, page...)
+	}
+	if firstErr != nil {
+		return runs, &partialListError{err: firstErr}
+	}
+	return runs, nil
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"net/http/pprof"
+)
+
+// defaultPprofAddr only accepts connections from the host itself, so that
+// profiles are never exposed by accident.
+const defaultPprofAddr = "localhost:6060"
+
+// servePprof serves the net/http/pprof handlers on addr, separately from the
+// webhook and metrics listener.
+func servePprof(addr string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	log.Printf("Serving pprof on %s", addr)
+	log.Fatal(http.ListenAndServe(addr, mux))
+}
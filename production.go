@@ -0,0 +1,42 @@
+package main
+
+import (
+	"sync"
+)
+
+// defaultBranchCache remembers the default branch of each repository so that
+// it is only looked up once.
+type defaultBranchCache struct {
+	mu       sync.Mutex
+	branches map[string]string
+}
+
+var defaultBranches = &defaultBranchCache{branches: make(map[string]string)}
+
+func (c *defaultBranchCache) get(provider Provider, repoFullName string) (string, error) {
+	c.mu.Lock()
+	branch, ok := c.branches[repoFullName]
+	c.mu.Unlock()
+	if ok {
+		return branch, nil
+	}
+
+	repository, err := provider.GetRepository(repoFullName)
+	if err != nil {
+		return "", err
+	}
+
+	c.mu.Lock()
+	c.branches[repoFullName] = repository.DefaultBranch
+	c.mu.Unlock()
+	return repository.DefaultBranch, nil
+}
+
+// productionBranch returns the configured PRODUCTION_BRANCH, or the
+// repository's default branch if none is configured.
+func productionBranch(provider Provider, repoFullName string) (string, error) {
+	if cfg.ProductionBranch != "" {
+		return cfg.ProductionBranch, nil
+	}
+	return defaultBranches.get(provider, repoFullName)
+}
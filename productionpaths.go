@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/google/go-github/v45/github"
+)
+
+// maxChangedPathsCacheEntries bounds the memory used by changedPathsCache.
+const maxChangedPathsCacheEntries = 10000
+
+// changedPathsCache remembers whether a commit touched PRODUCTION_PATHS or the
+// path of a MONOREPO_SERVICES service. Commits never change, so entries stay
+// valid; the cache is simply emptied once it grows too large.
+var changedPathsCache = struct {
+	mu      sync.Mutex
+	touches map[string]bool
+}{touches: make(map[string]bool)}
+
+// touchesProductionPaths reports whether the commit sha changed a file under
+// PRODUCTION_PATHS. Every commit counts when PRODUCTION_PATHS is not set, as
+// do deployments whose commit is unknown.
+func touchesProductionPaths(provider Provider, repoFullName string, sha string) (bool, error) {
+	if len(cfg.ProductionPaths) == 0 || sha == "" {
+		return true, nil
+	}
+	return touchesPaths(provider, repoFullName, sha, "", isProductionPath)
+}
+
+// touchesPaths reports whether the commit sha changed a file for which match
+// returns true. scope tells apart the results of different match functions
+// in changedPathsCache.
+func touchesPaths(provider Provider, repoFullName string, sha string, scope string, match func(file string) bool) (bool, error) {
+	key := repoFullName + "@" + sha + "#" + scope
+	changedPathsCache.mu.Lock()
+	touches, ok := changedPathsCache.touches[key]
+	changedPathsCache.mu.Unlock()
+	if ok {
+		return touches, nil
+	}
+
+	files, err := provider.ListChangedFiles(repoFullName, sha)
+	if err != nil {
+		return false, err
+	}
+	touches = false
+	for _, file := range files {
+		if match(file) {
+			touches = true
+			break
+		}
+	}
+
+	changedPathsCache.mu.Lock()
+	if len(changedPathsCache.touches) >= maxChangedPathsCacheEntries {
+		changedPathsCache.touches = make(map[string]bool)
+	}
+	changedPathsCache.touches[key] = touches
+	changedPathsCache.mu.Unlock()
+	return touches, nil
+}
+
+// isProductionPath reports whether file is in one of the PRODUCTION_PATHS
+// directories or matches one of its patterns.
+func isProductionPath(file string) bool {
+	for _, pattern := range cfg.ProductionPaths {
+		if strings.HasPrefix(file, strings.TrimSuffix(pattern, "/")+"/") {
+			return true
+		}
+		if matched, _ := path.Match(pattern, file); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// filterProductionAttempts drops the attempts that did not deploy a change to
+// PRODUCTION_PATHS.
+func filterProductionAttempts(provider Provider, repoFullName string, attempts []deploymentAttempt) ([]deploymentAttempt, error) {
+	if len(cfg.ProductionPaths) == 0 {
+		return attempts, nil
+	}
+	var filtered []deploymentAttempt
+	for _, attempt := range attempts {
+		touches, err := touchesProductionPaths(provider, repoFullName, attempt.HeadSHA)
+		if err != nil {
+			return nil, err
+		}
+		if touches {
+			filtered = append(filtered, attempt)
+		}
+	}
+	return filtered, nil
+}
+
+func (p *githubProvider) ListChangedFiles(repoFullName string, sha string) ([]string, error) {
+	countGitHubCall("commits")
+	commit, _, err := p.client.Repositories.GetCommit(context.Background(), getOwner(repoFullName), getRepo(repoFullName), sha, &github.ListOptions{PerPage: 100})
+	if err != nil {
+		return nil, fmt.Errorf("fetching commit %s: %w", sha, err)
+	}
+
+	files := make([]string, 0, len(commit.Files))
+	for _, file := range commit.Files {
+		files = append(files, file.GetFilename())
+	}
+	return files, nil
+}
+
+func (p *gitlabProvider) ListChangedFiles(repoFullName string, sha string) ([]string, error) {
+	var diffs []struct {
+		OldPath string `json:"old_path"`
+		NewPath string `json:"new_path"`
+	}
+	if err := p.getAll(repoFullName, "/repository/commits/"+url.PathEscape(sha)+"/diff", url.Values{"per_page": {"100"}}, &diffs); err != nil {
+		// Without every changed file, the commit may wrongly appear to
+		// leave the production paths alone.
+		return nil, fmt.Errorf("fetching diff of %s: %v", sha, err)
+	}
+
+	files := make([]string, 0, len(diffs))
+	for _, diff := range diffs {
+		files = append(files, diff.NewPath)
+		if diff.OldPath != diff.NewPath {
+			files = append(files, diff.OldPath)
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"time"
+)
+
+const (
+	scmProviderGitHub = "github"
+	scmProviderGitLab = "gitlab"
+)
+
+// Provider abstracts the source control system DORA metrics are computed
+// from. Repositories are always identified as "owner/repo", and an empty branch
+// matches every branch.
+type Provider interface {
+	// ListDeploymentAttempts returns the finished deployments of branch
+	// created after since, read from the configured DEPLOYMENT_SOURCE. If
+	// the error is a partialListError, the attempts are the ones fetched.
+	ListDeploymentAttempts(repoFullName string, branch string, since time.Time) ([]deploymentAttempt, error)
+	// ListPipelineRuns returns the completed CI runs of branch created after
+	// since, or the ones fetched along with a partialListError.
+	ListPipelineRuns(repoFullName string, branch string, since time.Time) ([]pipelineRun, error)
+	// ListEnvironmentDeployments returns deployments of branch to
+	// environment created after since, oldest first. An empty environment
+	// matches every environment. If the error is a partialListError, the
+	// deployments are the ones fetched.
+	ListEnvironmentDeployments(repoFullName string, branch string, environment string, since time.Time) ([]deploymentResult, error)
+	// ListIncidents returns closed incidents updated after since, or the
+	// ones fetched along with a partialListError.
+	ListIncidents(repoFullName string, since time.Time) ([]incident, error)
+	// GetLabeledAt returns when label was first applied to the issue
+	// number, or the zero time if it never was.
+	GetLabeledAt(repoFullName string, number int, label string) (time.Time, error)
+	// ListOpenIncidents returns the incidents that are still open, or the
+	// ones fetched along with a partialListError.
+	ListOpenIncidents(repoFullName string) ([]incident, error)
+	// ListCommitTimes returns the commit times of the commits reachable from
+	// head but not from base. An empty base returns just the head commit.
+	ListCommitTimes(repoFullName string, base string, head string) ([]time.Time, error)
+	// ListChangeLabels returns the labels of the pull or merge requests
+	// that contain the commit sha.
+	ListChangeLabels(repoFullName string, sha string) ([]string, error)
+	// ListChangedFiles returns the paths of the files changed by the
+	// commit sha.
+	ListChangedFiles(repoFullName string, sha string) ([]string, error)
+	// ListMergedPullRequests returns the pull or merge requests merged into
+	// branch after since, or the ones fetched along with a partialListError.
+	ListMergedPullRequests(repoFullName string, branch string, since time.Time) ([]mergedPullRequest, error)
+	// GetRepository returns details of the repository itself.
+	GetRepository(repoFullName string) (*repositoryInfo, error)
+	// RepositoryURL returns the web page of the repository, without any API
+	// requests.
+	RepositoryURL(repoFullName string) string
+}
+
+type repositoryInfo struct {
+	CreatedAt     time.Time
+	DefaultBranch string
+}
+
+// pipelineRun is a completed CI run. Conclusion uses the GitHub Actions
+// vocabulary so that it can be passed to classifyConclusion.
+type pipelineRun struct {
+	CreatedAt   time.Time
+	CompletedAt time.Time
+	Conclusion  string
+	HeadSHA     string
+	// HeadCommitAt is the head commit's timestamp, if the provider returns it
+	// along with the run.
+	HeadCommitAt time.Time
+	// HeadCommitMessage is the head commit's message, if the provider returns
+	// it along with the run.
+	HeadCommitMessage string
+}
+
+// incident is a closed issue labeled as an incident.
+type incident struct {
+	// Number is the issue number (the iid on GitLab).
+	Number    int
+	CreatedAt time.Time
+	ClosedAt  time.Time
+	Body      string
+	Labels    []string
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/google/go-github/v45/github"
+)
+
+// PullRequestMetrics are flow metrics for teams whose unit of change is a
+// merged pull request rather than a deployment.
+type PullRequestMetrics struct {
+	// MergeFrequency is the average number of pull requests merged per day.
+	MergeFrequency float64 `json:"merge_frequency"`
+	// LeadTime is the average time from opening to merging, in minutes.
+	LeadTime float64 `json:"lead_time"`
+	Merged   int     `json:"merged"`
+}
+
+// mergedPullRequest is a pull or merge request merged into a branch.
+type mergedPullRequest struct {
+	CreatedAt time.Time
+	MergedAt  time.Time
+}
+
+// pullRequestMetrics returns the merge frequency and open-to-merge
+// lead time of the pull requests merged into branch in the WINDOW_DAYS window.
+func (c *Calculator) pullRequestMetrics(repoFullName string, branch string) (*PullRequestMetrics, error) {
+	log.Printf("Calculating pull request metrics for %s on branch %s", repoFullName, branch)
+
+	pulls, listErr := c.provider.ListMergedPullRequests(repoFullName, branch, c.now().AddDate(0, 0, -cfg.WindowDays))
+	if listErr != nil && !isPartialList(listErr) {
+		return nil, listErr
+	}
+
+	metrics := &PullRequestMetrics{Merged: len(pulls)}
+	if len(pulls) == 0 {
+		return metrics, listErr
+	}
+	var totalLeadTime float64
+	for _, pull := range pulls {
+		totalLeadTime += pull.MergedAt.Sub(pull.CreatedAt).Minutes()
+	}
+	metrics.MergeFrequency = float64(len(pulls)) / float64(cfg.WindowDays)
+	metrics.LeadTime = totalLeadTime / float64(len(pulls))
+	log.Printf("Calculated pull request metrics: %d merged, %.2f minutes lead time", metrics.Merged, metrics.LeadTime)
+	return metrics, listErr
+}
+
+// ListMergedPullRequests searches for pull requests merged into branch after
+// since. Search results do not carry the merge time, so the close time, which
+// is the same for merged pull requests, is used.
+func (p *githubProvider) ListMergedPullRequests(repoFullName string, branch string, since time.Time) ([]mergedPullRequest, error) {
+	query := fmt.Sprintf("repo:%s is:pr is:merged merged:>=%s", repoFullName, since.UTC().Format("2006-01-02"))
+	if branch != "" {
+		query += fmt.Sprintf(" base:%q", branch)
+	}
+
+	countGitHubCall("search")
+	result, _, err := p.client.Search.Issues(context.Background(), query, &github.SearchOptions{
+		ListOptions: github.ListOptions{PerPage: 100},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("searching merged pull requests: %w", err)
+	}
+
+	var pulls []mergedPullRequest
+	for _, issue := range result.Issues {
+		if issue.ClosedAt == nil || !issue.ClosedAt.After(since) {
+			continue
+		}
+		pulls = append(pulls, mergedPullRequest{
+			CreatedAt: issue.GetCreatedAt(),
+			MergedAt:  issue.GetClosedAt(),
+		})
+	}
+	return pulls, nil
+}
+
+func (p *gitlabProvider) ListMergedPullRequests(repoFullName string, branch string, since time.Time) ([]mergedPullRequest, error) {
+	query := url.Values{
+		"state":         {"merged"},
+		"updated_after": {since.Format(time.RFC3339)},
+		"per_page":      {"100"},
+	}
+	if branch != "" {
+		query.Set("target_branch", branch)
+	}
+
+	var mergeRequests []struct {
+		CreatedAt time.Time  `json:"created_at"`
+		MergedAt  *time.Time `json:"merged_at"`
+	}
+	listErr := p.getAll(repoFullName, "/merge_requests", query, &mergeRequests)
+	if listErr != nil {
+		listErr = fmt.Errorf("fetching merge requests: %w", listErr)
+		if !isPartialList(listErr) {
+			return nil, listErr
+		}
+	}
+
+	var pulls []mergedPullRequest
+	for _, mergeRequest := range mergeRequests {
+		if mergeRequest.MergedAt == nil || !mergeRequest.MergedAt.After(since) {
+			continue
+		}
+		pulls = append(pulls, mergedPullRequest{
+			CreatedAt: mergeRequest.CreatedAt,
+			MergedAt:  *mergeRequest.MergedAt,
+		})
+	}
+	return pulls, listErr
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+type branchMetrics struct {
+	Branch string `json:"branch"`
+	storedMetrics
+}
+
+type branchesResponse struct {
+	Repo     string          `json:"repo"`
+	Branches []branchMetrics `json:"branches"`
+}
+
+// handleBranches serves GET /branches?repo=owner/name, listing every branch of
+// the repo that metrics have been computed for, with the last result.
+func handleBranches(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	owner, repo, err := parseRepoFullName(r.URL.Query().Get("repo"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	repoFullName := owner + "/" + repo
+
+	response := branchesResponse{Repo: repoFullName, Branches: []branchMetrics{}}
+	for branch, entry := range seenKeys.forRepo(repoFullName) {
+		response.Branches = append(response.Branches, branchMetrics{Branch: branch, storedMetrics: entry})
+	}
+	sort.Slice(response.Branches, func(i, j int) bool {
+		return response.Branches[i].Branch < response.Branches[j].Branch
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Error encoding branches to JSON: %v", err)
+	}
+}
+
+type summaryResponse struct {
+	GeneratedAt time.Time     `json:"generated_at"`
+	Series      []seriesEntry `json:"series"`
+}
+
+// handleSummary serves GET /summary, returning the last metrics computed for
+// every tracked repo/branch in a single document.
+func handleSummary(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	response := summaryResponse{GeneratedAt: time.Now().UTC(), Series: seenKeys.all()}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Error encoding summary to JSON: %v", err)
+	}
+}
+
+// handleRepoMetrics serves GET /metrics/repo?repo=owner/name, returning only
+// the DORA series of that repo in the Prometheus exposition format. The
+// series are rebuilt from the stored results on every request.
+func handleRepoMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	owner, repo, err := parseRepoFullName(r.URL.Query().Get("repo"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	repoGauges := newDoraGauges()
+	registry := prometheus.NewRegistry()
+	repoGauges.register(registry)
+	for _, entry := range seenKeys.forRepo(owner + "/" + repo) {
+		repoGauges.update(entry.Metrics, entry.ComputedAt)
+	}
+
+	promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	defaultWebhookQueueSize    = 100
+	defaultWebhookQueueWorkers = 2
+	defaultRecomputeRetries    = 3
+	// recomputeRetryBackoff is the delay before the first retry of a failed
+	// recompute. It doubles with every further retry.
+	recomputeRetryBackoff = time.Second
+)
+
+// webhookQueue processes webhook-triggered recomputes in the background. It
+// is nil unless ASYNC_WEBHOOKS is set.
+var webhookQueue *recomputeQueue
+
+// recomputeQueue is a bounded queue of repo/branches to recompute, drained by
+// a fixed number of workers. A repo/branch that is already waiting is not
+// queued twice.
+type recomputeQueue struct {
+	calculator *Calculator
+	notifier   *slackNotifier
+	jobs       chan seriesKey
+
+	mu      sync.Mutex
+	pending map[seriesKey]bool
+}
+
+func newRecomputeQueue(calculator *Calculator, notifier *slackNotifier, size int, workers int) *recomputeQueue {
+	q := &recomputeQueue{
+		calculator: calculator,
+		notifier:   notifier,
+		jobs:       make(chan seriesKey, size),
+		pending:    make(map[seriesKey]bool),
+	}
+	for i := 0; i < workers; i++ {
+		go q.work()
+	}
+	return q
+}
+
+// enqueue queues key for recomputation. It returns false if the queue is
+// full. Keys that are already waiting are coalesced and reported as queued.
+func (q *recomputeQueue) enqueue(key seriesKey) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.pending[key] {
+		return true
+	}
+	select {
+	case q.jobs <- key:
+		q.pending[key] = true
+		return true
+	default:
+		return false
+	}
+}
+
+// length returns the number of repo/branches waiting to be recomputed.
+func (q *recomputeQueue) length() float64 {
+	return float64(len(q.jobs))
+}
+
+func (q *recomputeQueue) work() {
+	for key := range q.jobs {
+		// Events arriving from now on need a new recompute to be seen.
+		q.mu.Lock()
+		delete(q.pending, key)
+		q.mu.Unlock()
+
+		q.process(key)
+	}
+}
+
+// process recomputes key, retrying failures with exponential backoff up to
+// RECOMPUTE_RETRIES times.
+func (q *recomputeQueue) process(key seriesKey) {
+	backoff := recomputeRetryBackoff
+	for attempt := 0; ; attempt++ {
+		metrics, err := recomputeMetrics(context.Background(), q.calculator, key.Repo, key.Branch, q.notifier)
+		if err == nil {
+			if cfg.AggregateBranches {
+				if _, err := recomputeMetrics(context.Background(), q.calculator, metrics.Repo, allBranches, q.notifier); err != nil {
+					log.Printf("Error calculating aggregate DORA metrics for %s: %v", metrics.Repo, err)
+				}
+			}
+			return
+		}
+		if errors.Is(err, errInvalidRepoFullName) || attempt >= cfg.RecomputeRetries {
+			log.Printf("Error calculating DORA metrics for %s on branch %s: %v", key.Repo, key.Branch, err)
+			return
+		}
+		log.Printf("Error calculating DORA metrics for %s on branch %s, retrying in %s: %v", key.Repo, key.Branch, backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
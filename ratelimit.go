@@ -0,0 +1,111 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// tokenBucket is a token-bucket rate limiter. Tokens accrue continuously at
+// rate per second up to capacity; each request takes one, waiting if the
+// bucket is empty.
+type tokenBucket struct {
+	mu       sync.Mutex
+	tokens   float64
+	capacity float64
+	rate     float64
+	last     time.Time
+}
+
+// newTokenBucket allows perHour requests an hour, in bursts of at most one
+// minute's worth.
+func newTokenBucket(perHour int) *tokenBucket {
+	capacity := math.Max(1, float64(perHour)/60)
+	return &tokenBucket{
+		tokens:   capacity,
+		capacity: capacity,
+		rate:     float64(perHour) / time.Hour.Seconds(),
+		last:     time.Now(),
+	}
+}
+
+// refill adds the tokens accrued since the last call. b.mu must be held.
+func (b *tokenBucket) refill(now time.Time) {
+	b.tokens = math.Min(b.capacity, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+}
+
+// take blocks until a token is available and takes it.
+func (b *tokenBucket) take() {
+	b.mu.Lock()
+	b.refill(time.Now())
+	b.tokens--
+	wait := time.Duration(0)
+	if b.tokens < 0 {
+		wait = time.Duration(-b.tokens / b.rate * float64(time.Second))
+	}
+	b.mu.Unlock()
+
+	// The token is already reserved, so concurrent callers queue behind it.
+	time.Sleep(wait)
+}
+
+// remaining returns the number of requests that can be made without waiting.
+func (b *tokenBucket) remaining() float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill(time.Now())
+	return math.Max(0, b.tokens)
+}
+
+// rateLimitedTransport takes a token from limiter before every request.
+type rateLimitedTransport struct {
+	next    http.RoundTripper
+	limiter *tokenBucket
+}
+
+func (t *rateLimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.limiter.take()
+	return t.next.RoundTrip(req)
+}
+
+// rateLimitObserver records the rate limit GitHub reports in the headers of
+// every response, so that the most recent one is exposed as metrics.
+type rateLimitObserver struct {
+	next http.RoundTripper
+}
+
+func (t *rateLimitObserver) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err == nil {
+		observeRateLimit(resp.Header)
+	}
+	return resp, err
+}
+
+// observeRateLimit sets the rate limit gauges from the X-RateLimit-* headers
+// of a GitHub API response. Responses without them, such as artifact
+// downloads, are ignored.
+func observeRateLimit(header http.Header) {
+	limit, err := strconv.Atoi(header.Get("X-RateLimit-Limit"))
+	if err != nil {
+		return
+	}
+	remaining, err := strconv.Atoi(header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return
+	}
+	reset, err := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return
+	}
+	resource := header.Get("X-RateLimit-Resource")
+	if resource == "" {
+		resource = "core"
+	}
+	githubRateLimit.WithLabelValues(resource).Set(float64(limit))
+	githubRateLimitRemaining.WithLabelValues(resource).Set(float64(remaining))
+	githubRateLimitReset.WithLabelValues(resource).Set(float64(reset))
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// newRecomputeHandler serves POST /recompute with a {"repo", "branch"} body,
+// recomputing the metrics of a repo/branch as if a webhook had arrived, e.g.
+// after incident labels were corrected. Requests must carry adminToken as a
+// bearer token.
+func newRecomputeHandler(calculator *Calculator, adminToken string, notifier *slackNotifier) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !hasAdminToken(r, adminToken) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var item batchRequestItem
+		if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
+			log.Printf("Error decoding recompute request: %v", err)
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if _, _, err := parseRepoFullName(item.Repo); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if item.Branch == "" {
+			http.Error(w, "missing branch", http.StatusBadRequest)
+			return
+		}
+
+		log.Printf("Received recompute request for %s on branch %s", item.Repo, item.Branch)
+		handleMetricsUpdate(calculator, item.Repo, item.Branch, notifier, w)
+	}
+}
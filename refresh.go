@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"log"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+)
+
+// keyedMutex provides a mutex per seriesKey. Entries are removed once no
+// goroutine holds or waits for them.
+type keyedMutex struct {
+	mu    sync.Mutex
+	locks map[seriesKey]*refCountedMutex
+}
+
+type refCountedMutex struct {
+	sync.Mutex
+	refs int
+}
+
+func newKeyedMutex() *keyedMutex {
+	return &keyedMutex{locks: make(map[seriesKey]*refCountedMutex)}
+}
+
+// lock blocks until the mutex for key is held and returns a function that
+// releases it.
+func (k *keyedMutex) lock(key seriesKey) func() {
+	k.mu.Lock()
+	m, ok := k.locks[key]
+	if !ok {
+		m = &refCountedMutex{}
+		k.locks[key] = m
+	}
+	m.refs++
+	k.mu.Unlock()
+
+	m.Lock()
+	return func() {
+		m.Unlock()
+		k.mu.Lock()
+		m.refs--
+		if m.refs == 0 {
+			delete(k.locks, key)
+		}
+		k.mu.Unlock()
+	}
+}
+
+// recomputeLocks serializes recalculations of the same repo/branch.
+var recomputeLocks = newKeyedMutex()
+
+// runRefreshLoop recomputes the metrics for every seen repo/branch each
+// interval, keeping the gauges fresh when no webhooks arrive. If discover is
+// not nil, the repo/branches it returns are refreshed as well. The first
+// refresh happens after a random fraction of the interval so that replicas
+// started together do not refresh in lockstep.
+func runRefreshLoop(calculator *Calculator, interval time.Duration, notifier *slackNotifier, discover func() ([]seriesKey, error)) {
+	time.Sleep(rand.N(interval))
+	refreshAll(calculator, notifier, discover)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		refreshAll(calculator, notifier, discover)
+	}
+}
+
+// refreshAll recomputes the metrics for every seen and discovered
+// repo/branch.
+func refreshAll(calculator *Calculator, notifier *slackNotifier, discover func() ([]seriesKey, error)) {
+	keys := seenKeys.keys()
+	if discover != nil {
+		discovered, err := discover()
+		if err != nil {
+			log.Printf("Error discovering repositories: %v", err)
+		}
+		for _, key := range discovered {
+			if !slices.Contains(keys, key) {
+				keys = append(keys, key)
+			}
+		}
+	}
+	log.Printf("Refreshing DORA metrics for %d repo/branch combinations", len(keys))
+	refreshReviewLeadTimes(calculator)
+	for _, key := range keys {
+		if _, err := recomputeMetrics(context.Background(), calculator, key.Repo, key.Branch, notifier); err != nil {
+			log.Printf("Error refreshing DORA metrics for %s on branch %s: %v", key.Repo, key.Branch, err)
+		}
+	}
+}
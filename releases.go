@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v45/github"
+)
+
+// releaseSeriesBranch is the branch label of the series computed from
+// releases. Releases are keyed by their tag rather than by the branch they
+// target, whose target_commitish may name a branch the tag is no longer on or
+// a bare commit, so all the releases of a repo form one series.
+const releaseSeriesBranch = "tags"
+
+// maxReleaseCommitCacheEntries bounds the memory used by releaseCommitCache.
+const maxReleaseCommitCacheEntries = 10000
+
+// releaseCommitCache holds the commits release tags point at, by repo and
+// tag. It is emptied once it grows too large.
+var releaseCommitCache = struct {
+	mu      sync.Mutex
+	commits map[string]string
+}{commits: make(map[string]string)}
+
+// listReleases returns the published, non-draft, non-prerelease releases
+// that were published after since, oldest first. Releases are not tied to a
+// branch, so every series, like releaseSeriesBranch, gets all of them.
+func (p *githubProvider) listReleases(repoFullName string, since time.Time) ([]*github.RepositoryRelease, error) {
+	countGitHubCall("releases")
+	releases, _, err := p.client.Repositories.ListReleases(context.Background(), getOwner(repoFullName), getRepo(repoFullName), &github.ListOptions{PerPage: 100})
+	if err != nil {
+		return nil, fmt.Errorf("fetching releases: %w", err)
+	}
+
+	var published []*github.RepositoryRelease
+	for _, release := range releases {
+		if release.GetDraft() || release.GetPrerelease() || !release.GetPublishedAt().Time.After(since) {
+			continue
+		}
+		published = append(published, release)
+	}
+	sort.Slice(published, func(i, j int) bool {
+		return published[i].GetPublishedAt().Time.Before(published[j].GetPublishedAt().Time)
+	})
+	return published, nil
+}
+
+// releaseCommit returns the SHA of the commit the release's tag points at,
+// following annotated tags.
+func (p *githubProvider) releaseCommit(repoFullName string, release *github.RepositoryRelease) (string, error) {
+	key := repoFullName + "@" + release.GetTagName()
+	releaseCommitCache.mu.Lock()
+	sha, ok := releaseCommitCache.commits[key]
+	releaseCommitCache.mu.Unlock()
+	if ok {
+		return sha, nil
+	}
+
+	countGitHubCall("commits")
+	sha, _, err := p.client.Repositories.GetCommitSHA1(context.Background(), getOwner(repoFullName), getRepo(repoFullName), "refs/tags/"+release.GetTagName(), "")
+	if err != nil {
+		return "", fmt.Errorf("resolving release tag %s: %w", release.GetTagName(), err)
+	}
+
+	releaseCommitCache.mu.Lock()
+	if len(releaseCommitCache.commits) >= maxReleaseCommitCacheEntries {
+		releaseCommitCache.commits = make(map[string]string)
+	}
+	releaseCommitCache.commits[key] = sha
+	releaseCommitCache.mu.Unlock()
+	return sha, nil
+}
+
+// listDeploymentAttemptsFromReleases treats every published release as a
+// successful deployment of the commit its tag points at. Releases cannot
+// fail, so the change failure rate is always zero with this source.
+func (p *githubProvider) listDeploymentAttemptsFromReleases(repoFullName string, branch string, since time.Time) ([]deploymentAttempt, error) {
+	releases, err := p.listReleases(repoFullName, since)
+	if err != nil {
+		return nil, err
+	}
+
+	attempts := make([]deploymentAttempt, 0, len(releases))
+	for _, release := range releases {
+		sha, err := p.releaseCommit(repoFullName, release)
+		if err != nil {
+			return nil, err
+		}
+		attempts = append(attempts, deploymentAttempt{
+			Environment: defaultEnvironment,
+			HeadSHA:     sha,
+			Actor:       release.GetAuthor().GetLogin(),
+			CreatedAt:   release.GetPublishedAt().Time,
+			CompletedAt: release.GetPublishedAt().Time,
+			Successful:  true,
+		})
+	}
+	return attempts, nil
+}
+
+// listPipelineRunsFromReleases presents releases as runs for the lead time
+// calculation. GitHub reports a release's created_at as the date of its tagged
+// commit, so each run spans from that commit to publication, and its head is
+// the tagged commit so that the commits between consecutive releases can be
+// compared.
+func (p *githubProvider) listPipelineRunsFromReleases(repoFullName string, branch string, since time.Time) ([]pipelineRun, error) {
+	releases, err := p.listReleases(repoFullName, since)
+	if err != nil {
+		return nil, err
+	}
+
+	runs := make([]pipelineRun, 0, len(releases))
+	for _, release := range releases {
+		sha, err := p.releaseCommit(repoFullName, release)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, pipelineRun{
+			CreatedAt:    release.GetCreatedAt().Time,
+			CompletedAt:  release.GetPublishedAt().Time,
+			Conclusion:   "success",
+			HeadSHA:      sha,
+			HeadCommitAt: release.GetCreatedAt().Time,
+		})
+	}
+	return runs, nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/google/go-github/v45/github"
+)
+
+// restoredAt returns when incident was resolved: when RESOLUTION_LABEL was
+// first applied to it, or when it was closed if the label is not configured
+// or was never applied.
+func restoredAt(provider Provider, repoFullName string, incident incident) (time.Time, error) {
+	if cfg.ResolutionLabel == "" {
+		return incident.ClosedAt, nil
+	}
+	labeledAt, err := provider.GetLabeledAt(repoFullName, incident.Number, cfg.ResolutionLabel)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if labeledAt.IsZero() || labeledAt.After(incident.ClosedAt) {
+		return incident.ClosedAt, nil
+	}
+	return labeledAt, nil
+}
+
+func (p *githubProvider) GetLabeledAt(repoFullName string, number int, label string) (time.Time, error) {
+	countGitHubCall("issue_events")
+	events, _, err := p.client.Issues.ListIssueEvents(context.Background(), getOwner(repoFullName), getRepo(repoFullName), number, &github.ListOptions{PerPage: 100})
+	if err != nil {
+		return time.Time{}, fmt.Errorf("fetching events of issue #%d: %w", number, err)
+	}
+
+	for _, event := range events {
+		if event.GetEvent() == "labeled" && event.GetLabel().GetName() == label {
+			return event.GetCreatedAt(), nil
+		}
+	}
+	return time.Time{}, nil
+}
+
+func (p *gitlabProvider) GetLabeledAt(repoFullName string, number int, label string) (time.Time, error) {
+	var events []struct {
+		Action    string    `json:"action"`
+		CreatedAt time.Time `json:"created_at"`
+		Label     struct {
+			Name string `json:"name"`
+		} `json:"label"`
+	}
+	err := p.getAll(repoFullName, "/issues/"+strconv.Itoa(number)+"/resource_label_events", url.Values{"per_page": {"100"}}, &events)
+	if err != nil && !isPartialList(err) {
+		return time.Time{}, fmt.Errorf("fetching label events of issue #%d: %w", number, err)
+	}
+
+	// The events are oldest first, so a label added in the pages fetched
+	// was first added there even if a later page failed.
+	for _, event := range events {
+		if event.Action == "add" && event.Label.Name == label {
+			return event.CreatedAt, nil
+		}
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("fetching label events of issue #%d: %v", number, err)
+	}
+	return time.Time{}, nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// reviewWindow is how long merged pull requests count towards the review lead
+// time: WINDOW_DAYS, like the other metrics.
+func reviewWindow() time.Duration {
+	return time.Duration(cfg.WindowDays) * 24 * time.Hour
+}
+
+type reviewSample struct {
+	MergedAt time.Time
+	Minutes  float64
+}
+
+// reviewTracker keeps the open-to-merge times of the pull requests merged
+// into each repo/branch within reviewWindow, as reported by webhooks.
+type reviewTracker struct {
+	mu      sync.Mutex
+	samples map[seriesKey][]reviewSample
+}
+
+func newReviewTracker() *reviewTracker {
+	return &reviewTracker{samples: make(map[seriesKey][]reviewSample)}
+}
+
+// record adds a pull request merged into key and returns the average review
+// lead time, in minutes, of the merges within the window ending at now.
+func (t *reviewTracker) record(key seriesKey, createdAt time.Time, mergedAt time.Time, now time.Time) float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.samples[key] = append(t.samples[key], reviewSample{MergedAt: mergedAt, Minutes: mergedAt.Sub(createdAt).Minutes()})
+	average, _ := t.averageLocked(key, now)
+	return average
+}
+
+// average returns the average review lead time, in minutes, of the merges
+// into key within the window ending at now, and false if there are none.
+func (t *reviewTracker) average(key seriesKey, now time.Time) (float64, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.averageLocked(key, now)
+}
+
+// averageLocked drops the merges out of the window before averaging. Keys
+// left without merges are removed.
+func (t *reviewTracker) averageLocked(key seriesKey, now time.Time) (float64, bool) {
+	cutoff := now.Add(-reviewWindow())
+	samples := t.samples[key][:0]
+	for _, sample := range t.samples[key] {
+		if sample.MergedAt.After(cutoff) {
+			samples = append(samples, sample)
+		}
+	}
+	if len(samples) == 0 {
+		delete(t.samples, key)
+		return 0, false
+	}
+	t.samples[key] = samples
+
+	var total float64
+	for _, sample := range samples {
+		total += sample.Minutes
+	}
+	return total / float64(len(samples)), true
+}
+
+// keys returns the repo/branches with merges recorded.
+func (t *reviewTracker) keys() []seriesKey {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	keys := make([]seriesKey, 0, len(t.samples))
+	for key := range t.samples {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// forget drops the merges recorded for key.
+func (t *reviewTracker) forget(key seriesKey) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.samples, key)
+}
+
+var reviews = newReviewTracker()
+
+// recordMergedPullRequest updates the review lead time of the branch a pull
+// request was merged into, with the window placed by the calculator's clock.
+// It needs no API requests.
+func recordMergedPullRequest(calculator *Calculator, repoFullName string, branch string, createdAt time.Time, mergedAt time.Time) {
+	owner, repo, err := parseRepoFullName(repoFullName)
+	if err != nil {
+		log.Printf("Error recording review lead time: %v", err)
+		return
+	}
+	key := seriesKey{Repo: owner + "/" + repo, Branch: branch}
+	average := reviews.record(key, createdAt, mergedAt, calculator.now())
+	reviewLeadTime.WithLabelValues(key.Branch, key.Repo).Set(average)
+	log.Printf("Review lead time for %s on branch %s: %.2f minutes", key.Repo, key.Branch, average)
+}
+
+// refreshReviewLeadTimes recalculates the review lead time of every tracked
+// repo/branch, so that merges age out of the window on quiet repos too. The
+// gauge of a repo/branch without merges left in the window is removed.
+func refreshReviewLeadTimes(calculator *Calculator) {
+	for _, key := range reviews.keys() {
+		average, ok := reviews.average(key, calculator.now())
+		if !ok {
+			reviewLeadTime.DeleteLabelValues(key.Branch, key.Repo)
+			continue
+		}
+		reviewLeadTime.WithLabelValues(key.Branch, key.Repo).Set(average)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+)
+
+// doraThresholds are the elite, high and medium performance limits of a
+// metric, from the State of DevOps report. Lower values are better unless
+// higherIsBetter is set.
+type doraThresholds struct {
+	elite, high, medium float64
+	higherIsBetter      bool
+}
+
+var compositeScoreThresholds = map[string]doraThresholds{
+	// Deployments per day: daily, weekly and monthly.
+	metricDeploymentFrequency: {elite: 1, high: 1.0 / 7, medium: 1.0 / 30, higherIsBetter: true},
+	// Minutes: a day, a week and a month.
+	metricLeadTimeForChanges: {elite: 24 * 60, high: 7 * 24 * 60, medium: 30 * 24 * 60},
+	// Hours: an hour, a day and a week.
+	metricTimeToRestoreService: {elite: 1, high: 24, medium: 7 * 24},
+	// Ratio of failed deployments.
+	metricChangeFailureRate: {elite: 0.05, high: 0.10, medium: 0.15},
+}
+
+// normalize maps value to 1 for elite, 2/3 for high, 1/3 for medium and 0 for
+// low performance.
+func (t doraThresholds) normalize(value float64) float64 {
+	better := func(limit float64) bool {
+		if t.higherIsBetter {
+			return value >= limit
+		}
+		return value <= limit
+	}
+	switch {
+	case better(t.elite):
+		return 1
+	case better(t.high):
+		return 2.0 / 3
+	case better(t.medium):
+		return 1.0 / 3
+	default:
+		return 0
+	}
+}
+
+// parseCompositeScoreWeights parses a comma-separated list of metric=weight
+// pairs. Metrics that are not listed keep a weight of 1.
+func parseCompositeScoreWeights(list string) (map[string]float64, error) {
+	pairs, err := parseKeyValueList(list)
+	if err != nil {
+		return nil, err
+	}
+	weights := map[string]float64{
+		metricDeploymentFrequency:  1,
+		metricLeadTimeForChanges:   1,
+		metricTimeToRestoreService: 1,
+		metricChangeFailureRate:    1,
+	}
+	for metric, value := range pairs {
+		if _, ok := weights[metric]; !ok {
+			return nil, fmt.Errorf("unknown metric %q", metric)
+		}
+		weight, err := strconv.ParseFloat(value, 64)
+		if err != nil || weight < 0 {
+			return nil, fmt.Errorf("weight for %q must be a non-negative number, got %q", metric, value)
+		}
+		weights[metric] = weight
+	}
+	return weights, nil
+}
+
+// compositeScore rolls the four metrics up into a single score from 0 to 100:
+// the weighted mean of each metric's normalized performance level. Metrics
+// that failed or have nothing to average over (no lead time samples, no
+// incidents, no deployment attempts) are left out.
+func compositeScore(metrics *DoraMetrics) float64 {
+	values := map[string]float64{metricDeploymentFrequency: metrics.DeploymentFrequency}
+	if metrics.LeadTimeSampleCount > 0 {
+		values[metricLeadTimeForChanges] = metrics.LeadTimeForChanges
+	}
+	if metrics.IncidentCount > 0 {
+		values[metricTimeToRestoreService] = metrics.TimeToRestoreService
+	}
+	if metrics.DeploymentAttempts > 0 {
+		values[metricChangeFailureRate] = metrics.ChangeFailureRate / changeFailureRateScale()
+	}
+
+	var total, totalWeight float64
+	for metric, value := range values {
+		if _, failed := metrics.Errors[metric]; failed || !metricEnabled(metric) || slices.Contains(metrics.InsufficientSamples, metric) {
+			continue
+		}
+		weight, ok := cfg.CompositeScoreWeights[metric]
+		if !ok {
+			weight = 1
+		}
+		total += weight * compositeScoreThresholds[metric].normalize(value)
+		totalWeight += weight
+	}
+	if totalWeight == 0 {
+		return 0
+	}
+	return 100 * total / totalWeight
+}
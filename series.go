@@ -0,0 +1,63 @@
+package main
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+)
+
+// forgetSeries stops tracking repo/branch and removes its series from
+// /metrics, e.g. once the branch has been deleted.
+func forgetSeries(repoFullName string, branch string) {
+	owner, repo, err := parseRepoFullName(repoFullName)
+	if err != nil {
+		log.Printf("Error removing series: %v", err)
+		return
+	}
+	key := seriesKey{Repo: owner + "/" + repo, Branch: branch}
+
+	unlock := recomputeLocks.lock(key)
+	defer unlock()
+
+	seenKeys.delete(key)
+	leadTimeObservations.forget(key)
+	deploymentObservations.forget(key)
+	reviews.forget(key)
+	gauges.deleteSeries(key)
+	log.Printf("Removed series for %s on branch %s", key.Repo, key.Branch)
+}
+
+// hasAdminToken reports whether r carries adminToken as a bearer token.
+func hasAdminToken(r *http.Request, adminToken string) bool {
+	return subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+adminToken)) == 1
+}
+
+// newDeleteSeriesHandler serves DELETE /metrics/series?repo=owner/name&branch=b,
+// removing the series of a repo/branch. Requests must carry adminToken as a
+// bearer token.
+func newDeleteSeriesHandler(adminToken string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			w.Header().Set("Allow", http.MethodDelete)
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !hasAdminToken(r, adminToken) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		query := r.URL.Query()
+		if _, _, err := parseRepoFullName(query.Get("repo")); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if query.Get("branch") == "" {
+			http.Error(w, "missing branch", http.StatusBadRequest)
+			return
+		}
+
+		forgetSeries(query.Get("repo"), query.Get("branch"))
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// parseSeverityWeights parses a comma-separated list of label=weight pairs.
+func parseSeverityWeights(list string) (map[string]float64, error) {
+	pairs, err := parseKeyValueList(list)
+	if err != nil {
+		return nil, err
+	}
+	weights := make(map[string]float64, len(pairs))
+	for label, value := range pairs {
+		weight, err := strconv.ParseFloat(value, 64)
+		if err != nil || weight <= 0 {
+			return nil, fmt.Errorf("weight for %q must be a positive number, got %q", label, value)
+		}
+		weights[label] = weight
+	}
+	return weights, nil
+}
+
+// incidentSeverity returns the first of labels that has a configured severity
+// weight, together with that weight. Incidents without a severity label have
+// weight 1.
+func incidentSeverity(labels []string) (string, float64) {
+	for _, label := range labels {
+		if weight, ok := cfg.SeverityWeights[label]; ok {
+			return label, weight
+		}
+	}
+	return "", 1
+}
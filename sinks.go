@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+const (
+	sinkPrometheus   = "prometheus"
+	sinkHTTP         = "http"
+	sinkLineProtocol = "line_protocol"
+)
+
+// MetricsSink publishes the metrics computed for a repo/branch, e.g. by
+// exposing them to a scraper or pushing them to another system.
+type MetricsSink interface {
+	Publish(metrics *DoraMetrics) error
+}
+
+// sinks are the MetricsSinks every recomputed result is published to.
+var sinks []MetricsSink
+
+// newMetricsSinks returns the sinks named in METRICS_SINKS. It is called
+// once the metrics have been registered.
+func newMetricsSinks() []MetricsSink {
+	var configured []MetricsSink
+	for _, name := range cfg.MetricsSinks {
+		switch name {
+		case sinkPrometheus:
+			configured = append(configured, &prometheusSink{gauges: gauges})
+		case sinkHTTP:
+			configured = append(configured, &httpSink{
+				url:        cfg.MetricsSinkURL,
+				httpClient: &http.Client{Timeout: 10 * time.Second},
+			})
+		case sinkLineProtocol:
+			configured = append(configured, &lineProtocolSink{w: os.Stdout})
+		}
+	}
+	return configured
+}
+
+// publishMetrics publishes metrics to every configured sink. A failing sink
+// does not keep the others from receiving the metrics.
+func publishMetrics(metrics *DoraMetrics) {
+	for _, sink := range sinks {
+		if err := sink.Publish(metrics); err != nil {
+			log.Printf("Error publishing metrics for %s on branch %s: %v", metrics.Repo, metrics.Branch, err)
+		}
+	}
+}
+
+// prometheusSink sets the gauges served from /metrics.
+type prometheusSink struct {
+	gauges *doraGauges
+}
+
+func (s *prometheusSink) Publish(metrics *DoraMetrics) error {
+	s.gauges.update(metrics, metrics.ComputedAt)
+	return nil
+}
+
+// httpSink posts the metrics as JSON to METRICS_SINK_URL, e.g. a collector
+// that forwards them to Datadog, CloudWatch or Kafka.
+type httpSink struct {
+	url        string
+	httpClient *http.Client
+}
+
+func (s *httpSink) Publish(metrics *DoraMetrics) error {
+	body, err := json.Marshal(metrics)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.httpClient.Post(s.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status from metrics sink: %s", resp.Status)
+	}
+	return nil
+}
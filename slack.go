@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultCFRAlertThreshold  = 0.15
+	defaultSlackAlertCooldown = time.Hour
+)
+
+// slackNotifier posts a message to a Slack incoming webhook when the change
+// failure rate for a repo/branch reaches the configured threshold. Alerts for
+// the same repo/branch are suppressed until the cooldown has elapsed.
+type slackNotifier struct {
+	webhookURL string
+	threshold  float64
+	cooldown   time.Duration
+	httpClient *http.Client
+
+	mu       sync.Mutex
+	lastSent map[string]time.Time
+}
+
+// newSlackNotifierFromEnv returns nil when SLACK_WEBHOOK_URL is not set.
+func newSlackNotifierFromEnv() (*slackNotifier, error) {
+	webhookURL := os.Getenv("SLACK_WEBHOOK_URL")
+	if webhookURL == "" {
+		return nil, nil
+	}
+
+	threshold := defaultCFRAlertThreshold
+	if v := os.Getenv("CFR_ALERT_THRESHOLD"); v != "" {
+		parsed, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CFR_ALERT_THRESHOLD %q: %w", v, err)
+		}
+		threshold = parsed
+	}
+
+	cooldown := defaultSlackAlertCooldown
+	if v := os.Getenv("SLACK_ALERT_COOLDOWN"); v != "" {
+		parsed, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SLACK_ALERT_COOLDOWN %q: %w", v, err)
+		}
+		cooldown = parsed
+	}
+
+	return &slackNotifier{
+		webhookURL: webhookURL,
+		threshold:  threshold,
+		cooldown:   cooldown,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		lastSent:   make(map[string]time.Time),
+	}, nil
+}
+
+// notifyIfNeeded sends an alert in the background when the change failure
+// rate is at or above the threshold and no alert was sent for the same
+// repo/branch within the cooldown. The message links to repoURL, the
+// repository's page on the provider.
+func (n *slackNotifier) notifyIfNeeded(repoFullName string, repoURL string, metrics *DoraMetrics) {
+	if n == nil || metrics.ChangeFailureRate/changeFailureRateScale() < n.threshold {
+		return
+	}
+	if slices.Contains(metrics.InsufficientSamples, metricChangeFailureRate) {
+		return
+	}
+
+	key := repoFullName + "@" + metrics.Branch
+	n.mu.Lock()
+	if last, ok := n.lastSent[key]; ok && time.Since(last) < n.cooldown {
+		n.mu.Unlock()
+		return
+	}
+	n.lastSent[key] = time.Now()
+	n.mu.Unlock()
+
+	go func() {
+		if err := n.send(repoFullName, repoURL, metrics); err != nil {
+			log.Printf("Error sending Slack notification: %v", err)
+		}
+	}()
+}
+
+func (n *slackNotifier) send(repoFullName string, repoURL string, metrics *DoraMetrics) error {
+	text := fmt.Sprintf(
+		":rotating_light: Change failure rate for <%s|%s> on branch `%s` is %.2f%% (threshold %.2f%%)\n"+
+			"• Deployment Frequency: %.2f/day\n"+
+			"• Lead Time for Changes: %.2f minutes\n"+
+			"• Time to Restore Service: %.2f hours\n"+
+			"• Successful / Failed Deployments: %d / %d",
+		repoURL, repoFullName, metrics.Branch,
+		metrics.ChangeFailureRate/changeFailureRateScale()*100, n.threshold*100,
+		metrics.DeploymentFrequency,
+		metrics.LeadTimeForChanges,
+		metrics.TimeToRestoreService,
+		metrics.SuccessfulDeployments, metrics.FailedDeployments,
+	)
+
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return err
+	}
+
+	resp, err := n.httpClient.Post(n.webhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status from Slack: %s", resp.Status)
+	}
+	return nil
+}
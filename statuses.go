@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/go-github/v45/github"
+)
+
+// listDeploymentAttemptsFromStatuses treats the final commit status with
+// context cfg.DeploymentStatusContext on each commit of branch as a
+// deployment, for CI systems that report through the legacy commit Status
+// API rather than the Checks API.
+func (p *githubProvider) listDeploymentAttemptsFromStatuses(repoFullName string, branch string, since time.Time) ([]deploymentAttempt, error) {
+	ctx := context.Background()
+	owner, repo := getOwner(repoFullName), getRepo(repoFullName)
+
+	countGitHubCall("commits")
+	commits, _, err := p.client.Repositories.ListCommits(ctx, owner, repo, &github.CommitsListOptions{
+		SHA:         branch,
+		Since:       since,
+		ListOptions: github.ListOptions{PerPage: 100},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("fetching commits: %w", err)
+	}
+
+	var attempts []deploymentAttempt
+	for _, commit := range commits {
+		countGitHubCall("statuses")
+		statuses, _, err := p.client.Repositories.ListStatuses(ctx, owner, repo, commit.GetSHA(), &github.ListOptions{PerPage: 100})
+		if err != nil {
+			return nil, fmt.Errorf("fetching statuses for %s: %w", commit.GetSHA(), err)
+		}
+
+		attempt, ok := deploymentFromStatuses(statuses, cfg.DeploymentStatusContext)
+		if !ok || !deploymentTime(attempt.CreatedAt, attempt.CompletedAt).After(since) {
+			continue
+		}
+		attempt.HeadSHA = commit.GetSHA()
+		attempts = append(attempts, attempt)
+	}
+	return attempts, nil
+}
+
+// deploymentFromStatuses returns the deployment recorded by the statuses of
+// one commit, which GitHub lists newest first: the latest final status with
+// the given context, started at the first status with that context. ok is
+// false while the deployment is still pending.
+func deploymentFromStatuses(statuses []*github.RepoStatus, statusContext string) (attempt deploymentAttempt, ok bool) {
+	var final *github.RepoStatus
+	var started time.Time
+	for _, status := range statuses {
+		if status.GetContext() != statusContext {
+			continue
+		}
+		if final == nil {
+			if status.GetState() == "pending" {
+				return deploymentAttempt{}, false
+			}
+			final = status
+		}
+		started = status.GetCreatedAt()
+	}
+	if final == nil {
+		return deploymentAttempt{}, false
+	}
+
+	switch final.GetState() {
+	case "success":
+		attempt.Successful = true
+	case "failure", "error":
+	default:
+		return deploymentAttempt{}, false
+	}
+	attempt.Environment = defaultEnvironment
+	attempt.Actor = final.GetCreator().GetLogin()
+	attempt.CreatedAt = started
+	attempt.CompletedAt = final.GetCreatedAt()
+	return attempt, true
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// seriesKey identifies the metrics computed for one branch of a repository.
+type seriesKey struct {
+	Repo   string `json:"repo"`
+	Branch string `json:"branch"`
+}
+
+// storedMetrics is the most recent result computed for a seriesKey.
+type storedMetrics struct {
+	Metrics    *DoraMetrics `json:"metrics"`
+	ComputedAt time.Time    `json:"computed_at"`
+}
+
+// seriesEntry is a stored result together with the key it is stored under.
+type seriesEntry struct {
+	Repo   string `json:"repo"`
+	Branch string `json:"branch"`
+	storedMetrics
+}
+
+// metricsStore is a concurrency-safe, in-memory record of the last metrics
+// computed for every repo/branch.
+type metricsStore struct {
+	mu      sync.Mutex
+	entries map[seriesKey]storedMetrics
+}
+
+func newMetricsStore() *metricsStore {
+	return &metricsStore{entries: make(map[seriesKey]storedMetrics)}
+}
+
+// put stores metrics under key as of their ComputedAt.
+func (s *metricsStore) put(key seriesKey, metrics *DoraMetrics) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[key] = storedMetrics{Metrics: metrics, ComputedAt: metrics.ComputedAt}
+}
+
+// get returns the stored entry for key, if any.
+func (s *metricsStore) get(key seriesKey) (storedMetrics, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[key]
+	return entry, ok
+}
+
+func (s *metricsStore) delete(key seriesKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+}
+
+// keys returns the stored keys sorted by repo and branch.
+func (s *metricsStore) keys() []seriesKey {
+	s.mu.Lock()
+	keys := make([]seriesKey, 0, len(s.entries))
+	for key := range s.entries {
+		keys = append(keys, key)
+	}
+	s.mu.Unlock()
+
+	sortSeriesKeys(keys)
+	return keys
+}
+
+// all returns every stored entry, sorted by repo and branch.
+func (s *metricsStore) all() []seriesEntry {
+	s.mu.Lock()
+	entries := make([]seriesEntry, 0, len(s.entries))
+	for key, entry := range s.entries {
+		entries = append(entries, seriesEntry{Repo: key.Repo, Branch: key.Branch, storedMetrics: entry})
+	}
+	s.mu.Unlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Repo != entries[j].Repo {
+			return entries[i].Repo < entries[j].Repo
+		}
+		return entries[i].Branch < entries[j].Branch
+	})
+	return entries
+}
+
+// forRepo returns the stored metrics of every branch of repoFullName, keyed
+// by branch.
+func (s *metricsStore) forRepo(repoFullName string) map[string]storedMetrics {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	branches := make(map[string]storedMetrics)
+	for key, entry := range s.entries {
+		if key.Repo == repoFullName {
+			branches[key.Branch] = entry
+		}
+	}
+	return branches
+}
+
+// countByRepo returns the number of stored branches of every repo.
+func (s *metricsStore) countByRepo() map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counts := make(map[string]int)
+	for key := range s.entries {
+		counts[key.Repo]++
+	}
+	return counts
+}
+
+// computedAfterSkew is how much later than t, a provider timestamp, the
+// calculation of the stored metrics must have started, by the local clock,
+// for computedAfter to hold. It covers a local clock running ahead of the
+// provider's.
+const computedAfterSkew = time.Minute
+
+// computedAfter reports whether the calculation of the stored metrics of
+// repo/branch started after t, so that they already reflect a change made at
+// t. A change made while a calculation was fetching may have been missed, and
+// is not covered.
+func computedAfter(repoFullName string, branch string, t time.Time) bool {
+	owner, repo, err := parseRepoFullName(repoFullName)
+	if err != nil || t.IsZero() {
+		return false
+	}
+	entry, ok := seenKeys.get(seriesKey{Repo: owner + "/" + repo, Branch: branch})
+	return ok && entry.ComputedAt.After(t.Add(computedAfterSkew))
+}
+
+func sortSeriesKeys(keys []seriesKey) {
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Repo != keys[j].Repo {
+			return keys[i].Repo < keys[j].Repo
+		}
+		return keys[i].Branch < keys[j].Branch
+	})
+}
+
+// seenKeys records the last metrics computed for every repo/branch seen via
+// webhooks, so the refresh loop knows what to recompute and the query
+// endpoints have something to serve.
+var seenKeys = newMetricsStore()
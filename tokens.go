@@ -0,0 +1,113 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// parseGitHubTokens combines the comma-separated GITHUB_TOKENS list with the
+// single GITHUB_TOKEN value.
+func parseGitHubTokens(tokensList string, token string) []string {
+	var tokens []string
+	for _, t := range strings.Split(tokensList, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			tokens = append(tokens, t)
+		}
+	}
+	if token != "" {
+		tokens = append(tokens, token)
+	}
+	return tokens
+}
+
+// pooledToken is a GitHub token and the core rate limit GitHub last reported
+// for it. remaining is -1 until a response has been seen.
+type pooledToken struct {
+	value     string
+	remaining int
+	reset     time.Time
+}
+
+// tokenPoolTransport authenticates each request with the token that has the
+// most rate limit left, taking turns between tokens that are equally good,
+// so that the combined limit of all tokens can be used.
+type tokenPoolTransport struct {
+	next http.RoundTripper
+
+	mu     sync.Mutex
+	tokens []*pooledToken
+	turn   int
+}
+
+func newTokenPoolTransport(tokens []string, next http.RoundTripper) *tokenPoolTransport {
+	t := &tokenPoolTransport{next: next}
+	for _, value := range tokens {
+		t.tokens = append(t.tokens, &pooledToken{value: value, remaining: -1})
+	}
+	return t
+}
+
+func (t *tokenPoolTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token := t.pick()
+
+	// A RoundTripper must not modify the request it was given.
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+token.value)
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Other resources, such as search, have small limits of their own that
+	// say nothing about the budget left for core requests.
+	if resource := resp.Header.Get("X-RateLimit-Resource"); resource != "" && resource != "core" {
+		return resp, nil
+	}
+	remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining"))
+	if err == nil {
+		reset, _ := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+		t.mu.Lock()
+		token.remaining = remaining
+		token.reset = time.Unix(reset, 0)
+		t.mu.Unlock()
+	}
+	return resp, nil
+}
+
+// pick returns the token with the most requests left. Tokens that have not
+// been used yet, or whose window has reset since, count as having the most.
+func (t *tokenPoolTransport) pick() *pooledToken {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	var best *pooledToken
+	bestRemaining := -1
+	for i := range t.tokens {
+		token := t.tokens[(t.turn+i)%len(t.tokens)]
+		remaining := token.remaining
+		if remaining < 0 || now.After(token.reset) {
+			remaining = math.MaxInt
+		}
+		if remaining > bestRemaining {
+			best, bestRemaining = token, remaining
+		}
+	}
+	t.turn = (t.turn + 1) % len(t.tokens)
+	return best
+}
+
+// remaining returns the rate limit last reported for the i-th token, or NaN
+// if none has been reported yet.
+func (t *tokenPoolTransport) remaining(i int) float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.tokens[i].remaining < 0 {
+		return math.NaN()
+	}
+	return float64(t.tokens[i].remaining)
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTokenPoolTransportIgnoresOtherResourceLimits(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Resource", r.URL.Query().Get("resource"))
+		w.Header().Set("X-RateLimit-Remaining", r.URL.Query().Get("remaining"))
+		w.Header().Set("X-RateLimit-Reset", "4102444800")
+	}))
+	defer server.Close()
+	transport := newTokenPoolTransport([]string{"token"}, http.DefaultTransport)
+	client := &http.Client{Transport: transport}
+
+	for _, query := range []string{"resource=core&remaining=4000", "resource=search&remaining=2"} {
+		resp, err := client.Get(server.URL + "/?" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if got := transport.remaining(0); got != 4000 {
+		t.Errorf("remaining = %v, want 4000 from the core response", got)
+	}
+}
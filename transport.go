@@ -0,0 +1,34 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// newGitHubTransport returns the transport used for GitHub API requests. Like
+// http.DefaultTransport it honors HTTPS_PROXY, HTTP_PROXY and NO_PROXY. When
+// caBundle is set, the PEM certificates in that file are trusted in addition
+// to the system roots, for proxies that re-sign TLS traffic.
+func newGitHubTransport(caBundle string) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if caBundle == "" {
+		return transport, nil
+	}
+
+	pem, err := os.ReadFile(caBundle)
+	if err != nil {
+		return nil, fmt.Errorf("reading GITHUB_CA_BUNDLE: %w", err)
+	}
+	roots, err := x509.SystemCertPool()
+	if err != nil {
+		roots = x509.NewCertPool()
+	}
+	if !roots.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("GITHUB_CA_BUNDLE %q contains no PEM certificates", caBundle)
+	}
+	transport.TLSClientConfig = &tls.Config{RootCAs: roots}
+	return transport, nil
+}